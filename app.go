@@ -11,12 +11,16 @@ import (
 	"path/filepath"
 	goruntime "runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"prochub/internal/config"
 	"prochub/internal/logging"
+	"prochub/internal/pipeline"
 	"prochub/internal/platform"
 	"prochub/internal/process"
+	"prochub/internal/proxy"
 	"prochub/internal/service"
 	"prochub/internal/store"
 
@@ -33,6 +37,8 @@ const (
 type App struct {
 	ctx          context.Context
 	pm           *process.Manager
+	pipelines    *pipeline.Runner
+	proxy        *proxy.Server
 	store        *store.Store
 	config       config.AppConfig
 	logHub       *logging.StreamHub
@@ -40,21 +46,109 @@ type App struct {
 	autoStartMgr *service.AutoStartManager
 	systemLogger *logging.RollingStore
 	dataDir      string
+	follower     atomic.Pointer[logFollower]
 }
 
 // ProcessLogger holds the logger for a specific process
 type ProcessLogger struct {
-	store *logging.RollingStore
-	hub   *logging.StreamHub
+	store  *logging.RollingStore
+	hub    *logging.StreamHub
+	seq    atomic.Uint64 // sequence number of the last line
+	levels atomic.Pointer[logging.Classifier]
+	counts logging.LevelCounter // errors and warnings per minute
+
+	mu   sync.Mutex
+	last time.Time // timestamp of the last line
+}
+
+// defaultLogBuffer is how many entries of a process are kept in memory
+const defaultLogBuffer = 100
+
+// stamp numbers a line and makes its timestamp unique within the process,
+// as log cursors rely on it: a line read no later than the one before is
+// stamped a nanosecond after it
+func (l *ProcessLogger) stamp(at time.Time) (uint64, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !at.After(l.last) {
+		at = l.last.Add(time.Nanosecond)
+	}
+	l.last = at
+	return l.seq.Add(1), at
+}
+
+const (
+	// followInterval batches the entries sent to a merged log view
+	followInterval = 200 * time.Millisecond
+	// followBacklog bounds the entries waiting for the next batch
+	followBacklog = 1000
+)
+
+// logFollower sends new entries of some processes to the UI in batches
+type logFollower struct {
+	names map[string]string // process names by ID
+	match func(logging.Entry) bool
+	emit  func([]logging.MergedEntry)
+
+	mu      sync.Mutex
+	pending []logging.MergedEntry
+	timer   *time.Timer
+	stopped bool
+}
+
+// add queues an entry if it belongs to the followed processes and matches
+func (f *logFollower) add(processID string, entry logging.Entry) {
+	name, ok := f.names[processID]
+	if !ok || !f.match(entry) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return
+	}
+	if len(f.pending) == followBacklog {
+		f.pending = f.pending[1:]
+	}
+	f.pending = append(f.pending, logging.MergedEntry{Entry: entry, ProcessID: processID, ProcessName: name})
+	if f.timer == nil {
+		f.timer = time.AfterFunc(followInterval, f.send)
+	}
+}
+
+// send emits the queued entries
+func (f *logFollower) send() {
+	f.mu.Lock()
+	batch := f.pending
+	f.pending = nil
+	f.timer = nil
+	stopped := f.stopped
+	f.mu.Unlock()
+	if !stopped && len(batch) > 0 {
+		f.emit(batch)
+	}
+}
+
+func (f *logFollower) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	if f.timer != nil {
+		f.timer.Stop()
+	}
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	dataDir := platform.MustDataDir()
 
+	pm := process.NewManager()
+
 	return &App{
 		dataDir:      dataDir,
-		pm:           process.NewManager(),
+		pm:           pm,
+		pipelines:    pipeline.NewRunner(pm),
+		proxy:        proxy.NewServer(pm),
 		store:        store.NewStore(dataDir),
 		logHub:       logging.NewStreamHub(100),
 		loggers:      make(map[string]*ProcessLogger),
@@ -100,38 +194,84 @@ func (a *App) startup(ctx context.Context) {
 	os.MkdirAll(systemLogDir, 0755)
 	a.systemLogger = logging.NewRollingStore(systemLogDir, 1000, 10)
 	// Set up log callback for process manager
-	a.pm.SetLogCallback(func(processID, stream, line string) {
+	a.pm.SetLogCallback(func(processID, stream, line string, at time.Time) {
 		logger, ok := a.loggers[processID]
 		if !ok {
 			return
 		}
 
+		seq, at := logger.stamp(at)
 		entry := logging.Entry{
-			Timestamp: time.Now(),
+			Seq:       seq,
+			Timestamp: at,
 			Stream:    stream,
 			Line:      line,
 		}
+		entry.ParseJSON()
+		logger.levels.Load().Classify(&entry)
+		logger.counts.Add(entry.Level, at)
 
 		// Store in memory hub
 		logger.hub.Push(entry)
+		if follower := a.follower.Load(); follower != nil {
+			follower.add(processID, entry)
+		}
 
 		// Store in rolling file
 		logger.store.Append(entry)
 	})
 
+	// Surface process alerts, such as crash loops, in the system log and UI
+	a.pm.SetAlertCallback(func(processID, message string) {
+		a.LogSystemError("alert", fmt.Sprintf("Process %s: %s", processID, message))
+		runtime.EventsEmit(a.ctx, "process:alert", map[string]string{
+			"id":      processID,
+			"message": message,
+		})
+	})
+
+	// Remember live process groups so a crashed session can be cleaned up
+	a.pm.SetStateFile(filepath.Join(a.dataDir, "process_state.json"))
+
+	// Keep allocated ports stable across sessions
+	a.pm.SetPortFile(filepath.Join(a.dataDir, "ports.json"))
+	a.pm.SetPortRange(a.config.PortRange[0], a.config.PortRange[1])
+
 	// Register saved processes
 	for _, def := range a.config.Processes {
 		a.pm.Register(def)
 
 		// Create logger for this process
-		processLogDir := filepath.Join(logDir, def.ID)
-		a.loggers[def.ID] = &ProcessLogger{
-			store: logging.NewRollingStore(processLogDir, a.config.MaxLogLines, a.config.MaxLogFiles),
-			hub:   logging.NewStreamHub(100),
-		}
+		a.loggers[def.ID] = a.newProcessLogger(def)
+	}
+
+	// Deal with processes left running by a session that did not exit cleanly
+	orphanPolicy, err := process.ParseOrphanPolicy(a.config.OrphanPolicy)
+	if err != nil {
+		// Leave orphans alone rather than guess what a mistyped policy meant
+		a.LogSystemError("startup", fmt.Sprintf("Invalid orphan policy, keeping leftover processes: %v", err))
+		orphanPolicy = process.OrphanKeep
+	}
+	orphans, _ := a.pm.SweepOrphans(orphanPolicy)
+	for _, orphan := range orphans {
+		a.LogSystemError("startup", fmt.Sprintf("Found process %s (pid %d) left by a previous session, policy: %s", orphan.ID, orphan.PID, orphanPolicy))
+	}
 
-		// Auto-start processes if configured
-		if def.AutoStart {
+	// Bind held sockets so connections queue up before the first start
+	for _, def := range a.config.Processes {
+		a.listenSockets(def)
+	}
+
+	// Route hostnames to managed processes if the proxy is enabled
+	if err := a.proxy.Apply(a.config.Proxy); err != nil {
+		a.LogSystemError("startup", fmt.Sprintf("Failed to start reverse proxy on %s: %v", a.config.Proxy.Address, err))
+	}
+
+	// Auto-start processes if configured
+	for _, def := range a.config.Processes {
+		if def.Kind == process.KindExternal {
+			a.watchExternal(def)
+		} else if def.AutoStart {
 			go a.pm.Start(ctx, def.ID)
 		}
 	}
@@ -160,15 +300,21 @@ func (a *App) AddProcess(def process.Definition) error {
 		def.RestartPolicy = process.RestartOnFailure
 	}
 
+	// Processes are long-running services unless marked as tasks
+	if def.Kind == "" {
+		def.Kind = process.KindService
+	}
+
+	// Surface problems early, but save the process anyway so it can be fixed
+	a.reportPreflight("AddProcess", def.ID, a.pm.Preflight(def))
+
 	// Register with process manager
 	a.pm.Register(def)
+	a.listenSockets(def)
+	a.watchExternal(def)
 
 	// Create logger for this process
-	logDir := filepath.Join(a.dataDir, a.config.LogDir, def.ID)
-	a.loggers[def.ID] = &ProcessLogger{
-		store: logging.NewRollingStore(logDir, a.config.MaxLogLines, a.config.MaxLogFiles),
-		hub:   logging.NewStreamHub(100),
-	}
+	a.loggers[def.ID] = a.newProcessLogger(def)
 
 	// Add to config and save
 	a.config.Processes = append(a.config.Processes, def)
@@ -179,10 +325,152 @@ func (a *App) AddProcess(def process.Definition) error {
 	return err
 }
 
+// ValidateProcess runs the preflight checks on a process definition and
+// returns the problems found, without starting anything
+func (a *App) ValidateProcess(def process.Definition) []process.Issue {
+	return a.pm.Preflight(def)
+}
+
+// ListSystemProcesses lists the running processes of the current user, with
+// their command line, working directory, environment and listening ports
+// where readable
+func (a *App) ListSystemProcesses() ([]process.SystemProcess, error) {
+	procs, err := a.pm.SystemProcesses()
+	if err != nil {
+		a.LogSystemError("ListSystemProcesses", fmt.Sprintf("Failed to list system processes: %v", err))
+	}
+	return procs, err
+}
+
+// DraftProcessFromPID turns a running process into a definition with its
+// command, working directory and environment prefilled. The draft is not
+// added; the UI passes it to AddProcess once reviewed.
+func (a *App) DraftProcessFromPID(pid int) (process.Definition, error) {
+	sp, err := a.pm.SystemProcess(pid)
+	if err != nil {
+		a.LogSystemError("DraftProcessFromPID", fmt.Sprintf("Failed to read process %d: %v", pid, err))
+		return process.Definition{}, err
+	}
+	return process.DraftDefinition(sp), nil
+}
+
+// reportPreflight logs preflight issues and sends them to the UI
+func (a *App) reportPreflight(method, id string, issues []process.Issue) {
+	if len(issues) == 0 {
+		return
+	}
+	for _, issue := range issues {
+		a.LogSystemError(method, fmt.Sprintf("Preflight %s for process %s: %s", issue.Level, id, issue.Message))
+	}
+	runtime.EventsEmit(a.ctx, "process:preflight", map[string]interface{}{
+		"id":     id,
+		"issues": issues,
+	})
+}
+
+// listenSockets binds the sockets ProcHub holds for a process, if any, and
+// watches them for on-demand processes
+func (a *App) listenSockets(def process.Definition) {
+	if len(def.Sockets) == 0 {
+		return
+	}
+	if err := a.pm.ListenSockets(a.ctx, def.ID); err != nil {
+		a.LogSystemError("listenSockets", fmt.Sprintf("Failed to bind sockets of process %s: %v", def.ID, err))
+	}
+}
+
+// newProcessLogger creates the log hub, files and level classifier of a
+// process. The hub starts with the end of its log files, so the log view
+// shows the previous session until the process prints again.
+func (a *App) newProcessLogger(def process.Definition) *ProcessLogger {
+	size := logBufferSize(def)
+	logger := &ProcessLogger{
+		store: a.newLogStore(def.ID),
+		hub:   logging.NewStreamHub(size),
+	}
+	logger.levels.Store(a.levelClassifier(def))
+
+	page, err := logging.History{Source: a.logSource(def.ID, logger)}.Before("", size)
+	if err != nil {
+		a.LogSystemError("newProcessLogger", fmt.Sprintf("Failed to read past logs of process %s: %v", def.ID, err))
+	}
+	for _, entry := range page.Entries {
+		logger.hub.Push(entry)
+		// Lines read from text files have no sequence number
+		if entry.Seq > logger.seq.Load() {
+			logger.seq.Store(entry.Seq)
+		}
+	}
+	if n := len(page.Entries); n > 0 {
+		logger.last = page.Entries[n-1].Timestamp
+	}
+	return logger
+}
+
+// logBufferSize returns how many log entries of a process are kept in memory
+func logBufferSize(def process.Definition) int {
+	if def.LogBuffer > 0 {
+		return def.LogBuffer
+	}
+	return defaultLogBuffer
+}
+
+// logSource returns the log files of a process for searching and paging
+func (a *App) logSource(id string, logger *ProcessLogger) logging.Source {
+	return logging.Source{
+		Dir:    filepath.Join(a.dataDir, a.config.LogDir, id),
+		Levels: logger.levels.Load(),
+	}
+}
+
+// levelClassifier builds the level classifier of a process. An invalid
+// pattern is logged and the common formats are used instead.
+func (a *App) levelClassifier(def process.Definition) *logging.Classifier {
+	levels, err := logging.NewClassifier(def.LevelPattern)
+	if err != nil {
+		a.LogSystemError("levelClassifier", fmt.Sprintf("Invalid level pattern of process %s: %v", def.ID, err))
+	}
+	return levels
+}
+
+// newLogStore creates the rolling log files of a process
+func (a *App) newLogStore(id string) *logging.RollingStore {
+	store := logging.NewRollingStore(filepath.Join(a.dataDir, a.config.LogDir, id), a.config.MaxLogLines, a.config.MaxLogFiles)
+	store.SetRotation(a.config.LogRotation)
+	store.SetSync(a.config.LogSync)
+	store.SetFormat(a.config.LogFormat)
+	return store
+}
+
+// watchExternal monitors an external process. It is only launched by its
+// command when auto-start is set.
+func (a *App) watchExternal(def process.Definition) {
+	if def.Kind != process.KindExternal {
+		return
+	}
+	if def.AutoStart {
+		go a.pm.Start(a.ctx, def.ID)
+		return
+	}
+	if err := a.pm.Watch(a.ctx, def.ID); err != nil {
+		a.LogSystemError("watchExternal", fmt.Sprintf("Failed to watch external process %s: %v", def.ID, err))
+	}
+}
+
+// isExternal reports whether a process was started by other means, which
+// removing or editing it must leave running
+func (a *App) isExternal(id string) bool {
+	snap, err := a.pm.Get(id)
+	return err == nil && snap.Definition.Kind == process.KindExternal
+}
+
 // RemoveProcess removes a process by ID
 func (a *App) RemoveProcess(id string) error {
 	// Stop the process first
-	err := a.pm.Stop(id)
+	var err error
+	if !a.isExternal(id) {
+		err = a.pm.Stop(id)
+	}
 	if err != nil {
 		a.LogSystemError("RemoveProcess", fmt.Sprintf("Failed to stop process %s: %v", id, err))
 	}
@@ -200,6 +488,9 @@ func (a *App) RemoveProcess(id string) error {
 	a.config.Processes = newProcesses
 
 	// Remove logger
+	if logger, ok := a.loggers[id]; ok {
+		logger.store.Close()
+	}
 	delete(a.loggers, id)
 
 	err = a.store.Save(a.config)
@@ -212,7 +503,10 @@ func (a *App) RemoveProcess(id string) error {
 // UpdateProcess updates a process configuration
 func (a *App) UpdateProcess(id string, def process.Definition) error {
 	// Stop the process first
-	err := a.pm.Stop(id)
+	var err error
+	if !a.isExternal(id) {
+		err = a.pm.Stop(id)
+	}
 	if err != nil {
 		a.LogSystemError("UpdateProcess", fmt.Sprintf("Failed to stop process %s: %v", id, err))
 	}
@@ -228,6 +522,13 @@ func (a *App) UpdateProcess(id string, def process.Definition) error {
 
 	// Re-register with process manager
 	a.pm.Register(def)
+	a.listenSockets(def)
+	a.watchExternal(def)
+	a.reportPreflight("UpdateProcess", id, a.pm.Preflight(def))
+	if logger, ok := a.loggers[id]; ok {
+		logger.levels.Store(a.levelClassifier(def))
+		logger.hub.SetLimit(logBufferSize(def))
+	}
 
 	// Save config
 	err = a.store.Save(a.config)
@@ -265,11 +566,73 @@ func (a *App) SetProcessAutoStart(id string, enabled bool) error {
 	return nil
 }
 
+// GetProcessStats returns the CPU, memory and uptime of a running process,
+// including external ones
+func (a *App) GetProcessStats(id string) (process.ProcessStats, error) {
+	stats, err := a.pm.Stats(id)
+	if err != nil && err != process.ErrNotRunning {
+		a.LogSystemError("GetProcessStats", fmt.Sprintf("Failed to get stats of process %s: %v", id, err))
+	}
+	return stats, err
+}
+
 // StartProcess starts a process by ID
 func (a *App) StartProcess(id string) error {
-	err := a.pm.Start(a.ctx, id)
+	snap, err := a.pm.Get(id)
 	if err != nil {
 		a.LogSystemError("StartProcess", fmt.Sprintf("Failed to start process %s: %v", id, err))
+		return err
+	}
+	// Catch missing commands, bad directories and busy ports before starting
+	if snap.Status == process.StatusStopped || snap.Status == process.StatusErrored || snap.Status == process.StatusIdle {
+		issues := a.pm.Preflight(snap.Definition)
+		a.reportPreflight("StartProcess", id, issues)
+		if err := process.PreflightError(issues); err != nil {
+			return err
+		}
+	}
+
+	err = a.pm.Start(a.ctx, id)
+	if err != nil {
+		a.LogSystemError("StartProcess", fmt.Sprintf("Failed to start process %s: %v", id, err))
+	}
+	return err
+}
+
+// RunProcess runs a process now, refusing to start a second copy while one
+// is still running
+func (a *App) RunProcess(id string) error {
+	err := a.pm.RunNow(a.ctx, id)
+	if err != nil {
+		a.LogSystemError("RunProcess", fmt.Sprintf("Failed to run process %s: %v", id, err))
+	}
+	return err
+}
+
+// ResumeProcess resumes a process held back by crash-loop detection
+func (a *App) ResumeProcess(id string) error {
+	err := a.pm.Resume(a.ctx, id)
+	if err != nil {
+		a.LogSystemError("ResumeProcess", fmt.Sprintf("Failed to resume process %s: %v", id, err))
+	}
+	return err
+}
+
+// SignalProcess sends a signal (e.g. "HUP", "USR1") to a running process,
+// or to its whole process group when group is set
+func (a *App) SignalProcess(id string, signal string, group bool) error {
+	err := a.pm.Signal(id, signal, group)
+	if err != nil {
+		a.LogSystemError("SignalProcess", fmt.Sprintf("Failed to send %s to process %s: %v", signal, id, err))
+	}
+	return err
+}
+
+// ReloadProcess runs the reload action configured for a process
+func (a *App) ReloadProcess(id string) error {
+	err := a.pm.Reload(a.ctx, id)
+	if err != nil {
+		a.LogSystemError("ReloadProcess", fmt.Sprintf("Failed to reload process %s: %v", id, err))
 	}
 	return err
 }
@@ -283,31 +646,347 @@ func (a *App) StopProcess(id string) error {
 	return err
 }
 
-// RestartProcess restarts a process by ID
+// RestartProcess restarts a process by ID. Processes with the rolling
+// restart mode keep serving until their replacement is ready.
 func (a *App) RestartProcess(id string) error {
+	snap, err := a.pm.Get(id)
+	if err != nil {
+		a.LogSystemError("RestartProcess", fmt.Sprintf("Failed to restart process %s: %v", id, err))
+		return err
+	}
+	if snap.Definition.RestartMode == process.RestartModeRolling && snap.Status == process.StatusRunning {
+		err = a.pm.RollingRestart(a.ctx, id)
+		if err != nil {
+			a.LogSystemError("RestartProcess", fmt.Sprintf("Rolling restart of process %s failed: %v", id, err))
+		}
+		return err
+	}
+
 	if err := a.pm.Stop(id); err != nil {
 		a.LogSystemError("RestartProcess", fmt.Sprintf("Failed to stop process %s during restart: %v", id, err))
 		return err
 	}
-	err := a.pm.Start(a.ctx, id)
+	err = a.pm.Start(a.ctx, id)
 	if err != nil {
 		a.LogSystemError("RestartProcess", fmt.Sprintf("Failed to start process %s during restart: %v", id, err))
 	}
 	return err
 }
 
+// ListOrphanProcesses returns processes left running by a previous session
+// that are waiting to be killed or adopted
+func (a *App) ListOrphanProcesses() []process.Orphan {
+	return a.pm.Orphans()
+}
+
+// KillOrphanProcess terminates a process left running by a previous session
+func (a *App) KillOrphanProcess(id string) error {
+	err := a.pm.KillOrphan(id)
+	if err != nil {
+		a.LogSystemError("KillOrphanProcess", fmt.Sprintf("Failed to kill orphan process %s: %v", id, err))
+	}
+	return err
+}
+
+// AdoptOrphanProcess takes a process left running by a previous session
+// under management
+func (a *App) AdoptOrphanProcess(id string) error {
+	err := a.pm.Adopt(id)
+	if err != nil {
+		a.LogSystemError("AdoptOrphanProcess", fmt.Sprintf("Failed to adopt orphan process %s: %v", id, err))
+	}
+	return err
+}
+
 // ListProcesses returns all processes with their status
 func (a *App) ListProcesses() []process.Snapshot {
 	return a.pm.List()
 }
 
-// GetProcessLogs returns logs for a specific process
-func (a *App) GetProcessLogs(id string) []logging.Entry {
+// GetProcessLogs returns logs for a specific process, only those as severe
+// as minLevel unless it is empty
+func (a *App) GetProcessLogs(id string, minLevel string) []logging.Entry {
 	logger, ok := a.loggers[id]
 	if !ok {
 		return []logging.Entry{}
 	}
-	return logger.hub.Snapshot()
+	return logging.FilterLevel(logger.hub.Snapshot(), minLevel)
+}
+
+// GetProcessLogsBefore returns up to limit log entries of a process older
+// than the one cursor marks, or its newest ones when cursor is empty, from
+// memory and then from its log files. The cursor of the page continues
+// backwards, so the log view can scroll through the whole history.
+func (a *App) GetProcessLogsBefore(id string, cursor string, limit int) (logging.Page, error) {
+	history, err := a.logHistory(id)
+	if err != nil {
+		return logging.Page{}, err
+	}
+	page, err := history.Before(cursor, limit)
+	if err != nil {
+		a.LogSystemError("GetProcessLogsBefore", fmt.Sprintf("Failed to read logs of process %s: %v", id, err))
+	}
+	return page, err
+}
+
+// GetProcessLogsSince returns up to limit log entries of a process newer
+// than the one cursor marks. The cursor of the page continues forwards, for
+// following new entries.
+func (a *App) GetProcessLogsSince(id string, cursor string, limit int) (logging.Page, error) {
+	history, err := a.logHistory(id)
+	if err != nil {
+		return logging.Page{}, err
+	}
+	page, err := history.Since(cursor, limit)
+	if err != nil {
+		a.LogSystemError("GetProcessLogsSince", fmt.Sprintf("Failed to read logs of process %s: %v", id, err))
+	}
+	return page, err
+}
+
+// logHistory returns the logs of a process for paging, with buffered lines
+// written to its files
+func (a *App) logHistory(id string) (logging.History, error) {
+	logger, ok := a.loggers[id]
+	if !ok {
+		return logging.History{}, process.ErrNotFound
+	}
+	logger.store.Flush()
+	return logging.History{Hub: logger.hub, Source: a.logSource(id, logger)}, nil
+}
+
+// GetLogLevelCounts returns the errors and warnings a process logged in
+// each minute of the last hour, oldest first
+func (a *App) GetLogLevelCounts(id string) []logging.LevelCount {
+	logger, ok := a.loggers[id]
+	if !ok {
+		return []logging.LevelCount{}
+	}
+	return logger.counts.Counts(time.Now())
+}
+
+// GetMergedLogs returns the newest entries of several processes matching
+// query, interleaved oldest first and tagged with their process, from
+// memory and then from the log files. All processes are merged when ids is
+// empty.
+func (a *App) GetMergedLogs(ids []string, query logging.Query) ([]logging.MergedEntry, error) {
+	feeds, err := a.logFeeds(ids)
+	if err != nil {
+		a.LogSystemError("GetMergedLogs", fmt.Sprintf("Failed to merge logs of %v: %v", ids, err))
+		return nil, err
+	}
+	entries, err := logging.Merge(feeds, query)
+	if err != nil {
+		a.LogSystemError("GetMergedLogs", fmt.Sprintf("Failed to merge logs of %v: %v", ids, err))
+	}
+	return entries, err
+}
+
+// FollowLogs sends new entries of several processes matching query to the
+// UI as they arrive, in batches on the logs:follow event. All processes are
+// followed when ids is empty. The time range, limit and cursor of query are
+// not used. It replaces the previous follow, if any.
+func (a *App) FollowLogs(ids []string, query logging.Query) error {
+	feeds, err := a.logFeeds(ids)
+	if err != nil {
+		a.LogSystemError("FollowLogs", fmt.Sprintf("Failed to follow logs of %v: %v", ids, err))
+		return err
+	}
+	match, err := logging.Matcher(query)
+	if err != nil {
+		a.LogSystemError("FollowLogs", fmt.Sprintf("Failed to follow logs of %v: %v", ids, err))
+		return err
+	}
+
+	names := make(map[string]string, len(feeds))
+	for _, feed := range feeds {
+		names[feed.ID] = feed.Name
+	}
+	follower := &logFollower{names: names, match: match, emit: func(entries []logging.MergedEntry) {
+		runtime.EventsEmit(a.ctx, "logs:follow", entries)
+	}}
+	if previous := a.follower.Swap(follower); previous != nil {
+		previous.stop()
+	}
+	return nil
+}
+
+// UnfollowLogs stops the events started by FollowLogs
+func (a *App) UnfollowLogs() {
+	if previous := a.follower.Swap(nil); previous != nil {
+		previous.stop()
+	}
+}
+
+// logFeeds returns the logs of the processes in ids, or of all processes
+// when it is empty, with buffered lines written to their files
+func (a *App) logFeeds(ids []string) ([]logging.Feed, error) {
+	if len(ids) == 0 {
+		for _, p := range a.config.Processes {
+			ids = append(ids, p.ID)
+		}
+	}
+	feeds := make([]logging.Feed, 0, len(ids))
+	for _, id := range ids {
+		logger, ok := a.loggers[id]
+		snap, err := a.pm.Get(id)
+		if !ok || err != nil {
+			return nil, process.ErrNotFound
+		}
+		logger.store.Flush()
+		feeds = append(feeds, logging.Feed{
+			ID:     id,
+			Name:   snap.Definition.Name,
+			Hub:    logger.hub,
+			Source: a.logSource(id, logger),
+		})
+	}
+	return feeds, nil
+}
+
+// SearchLogs searches the log files of a process, or of all processes when
+// id is empty, newest first. Pass the NextCursor of a result as the query
+// cursor to get the next page.
+func (a *App) SearchLogs(id string, query logging.Query) (logging.Result, error) {
+	sources := make(map[string]logging.Source)
+	for processID, logger := range a.loggers {
+		if id == "" || processID == id {
+			// Include lines still buffered
+			logger.store.Flush()
+			sources[processID] = a.logSource(processID, logger)
+		}
+	}
+	if id != "" && len(sources) == 0 {
+		return logging.Result{}, process.ErrNotFound
+	}
+	result, err := logging.Search(sources, query)
+	if err != nil {
+		a.LogSystemError("SearchLogs", fmt.Sprintf("Failed to search logs of %q: %v", id, err))
+	}
+	return result, err
+}
+
+// ListPipelines returns all pipeline definitions
+func (a *App) ListPipelines() []pipeline.Definition {
+	if a.config.Pipelines == nil {
+		return []pipeline.Definition{}
+	}
+	return a.config.Pipelines
+}
+
+// SavePipeline adds a new pipeline or updates the one with the same ID
+func (a *App) SavePipeline(def pipeline.Definition) error {
+	if def.ID == "" {
+		def.ID = uuid.New().String()
+	}
+	if err := pipeline.Validate(def); err != nil {
+		return err
+	}
+	for _, step := range def.Steps {
+		if _, err := a.pm.Get(step.ProcessID); err != nil {
+			return fmt.Errorf("step %s: process %s not found", step.ID, step.ProcessID)
+		}
+	}
+
+	updated := false
+	for i, p := range a.config.Pipelines {
+		if p.ID == def.ID {
+			a.config.Pipelines[i] = def
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		a.config.Pipelines = append(a.config.Pipelines, def)
+	}
+
+	err := a.store.Save(a.config)
+	if err != nil {
+		a.LogSystemError("SavePipeline", fmt.Sprintf("Failed to save config after saving pipeline %s: %v", def.Name, err))
+	}
+	return err
+}
+
+// RemovePipeline removes a pipeline by ID, cancelling an active run
+func (a *App) RemovePipeline(id string) error {
+	a.pipelines.Forget(id)
+
+	newPipelines := make([]pipeline.Definition, 0)
+	for _, p := range a.config.Pipelines {
+		if p.ID != id {
+			newPipelines = append(newPipelines, p)
+		}
+	}
+	a.config.Pipelines = newPipelines
+
+	err := a.store.Save(a.config)
+	if err != nil {
+		a.LogSystemError("RemovePipeline", fmt.Sprintf("Failed to save config after removing pipeline %s: %v", id, err))
+	}
+	return err
+}
+
+// RunPipeline starts a run of a pipeline and returns it immediately
+func (a *App) RunPipeline(id string) (pipeline.Run, error) {
+	for _, def := range a.config.Pipelines {
+		if def.ID == id {
+			run, err := a.pipelines.Start(a.ctx, def)
+			if err != nil {
+				a.LogSystemError("RunPipeline", fmt.Sprintf("Failed to run pipeline %s: %v", id, err))
+			}
+			return run, err
+		}
+	}
+	return pipeline.Run{}, fmt.Errorf("pipeline %s not found", id)
+}
+
+// CancelPipelineRun stops a running pipeline
+func (a *App) CancelPipelineRun(runID string) error {
+	return a.pipelines.Cancel(runID)
+}
+
+// GetPipelineRuns returns the recent runs of a pipeline, newest first
+func (a *App) GetPipelineRuns(id string) []pipeline.Run {
+	return a.pipelines.Runs(id)
+}
+
+// GetPipelineStepLogs returns the log entries a step produced during a run,
+// read from the log files of its process and then from its hub
+func (a *App) GetPipelineStepLogs(runID, stepID string) ([]logging.Entry, error) {
+	run, err := a.pipelines.Get(runID)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range run.Steps {
+		if step.StepID != stepID {
+			continue
+		}
+		entries := []logging.Entry{}
+		history, err := a.logHistory(step.ProcessID)
+		if err != nil || step.StartedAt == nil {
+			return entries, nil
+		}
+		// Start just before the step, as the cursor itself is left out
+		cursor := logging.Cursor(logging.Entry{Timestamp: step.StartedAt.Add(-time.Nanosecond)})
+		for {
+			page, err := history.Since(cursor, 0)
+			if err != nil {
+				a.LogSystemError("GetPipelineStepLogs", fmt.Sprintf("Failed to read logs of process %s: %v", step.ProcessID, err))
+				return nil, err
+			}
+			for _, entry := range page.Entries {
+				if step.FinishedAt != nil && entry.Timestamp.After(*step.FinishedAt) {
+					return entries, nil
+				}
+				entries = append(entries, entry)
+			}
+			if !page.More || page.Cursor == cursor {
+				return entries, nil
+			}
+			cursor = page.Cursor
+		}
+	}
+	return nil, fmt.Errorf("step %s not found in run %s", stepID, runID)
 }
 
 // GetConfig returns the current configuration
@@ -317,8 +996,21 @@ func (a *App) GetConfig() config.AppConfig {
 
 // UpdateConfig updates the configuration
 func (a *App) UpdateConfig(cfg config.AppConfig) error {
+	if _, err := process.ParseOrphanPolicy(cfg.OrphanPolicy); err != nil {
+		a.LogSystemError("UpdateConfig", fmt.Sprintf("Rejected config: %v", err))
+		return err
+	}
 	oldLocale := a.config.Locale
 	a.config = cfg
+	a.pm.SetPortRange(cfg.PortRange[0], cfg.PortRange[1])
+	for _, logger := range a.loggers {
+		logger.store.SetRotation(cfg.LogRotation)
+		logger.store.SetSync(cfg.LogSync)
+		logger.store.SetFormat(cfg.LogFormat)
+	}
+	if err := a.proxy.Apply(cfg.Proxy); err != nil {
+		a.LogSystemError("UpdateConfig", fmt.Sprintf("Failed to start reverse proxy on %s: %v", cfg.Proxy.Address, err))
+	}
 	
 	// Update tray language if locale changed
 	if oldLocale != cfg.Locale {
@@ -442,6 +1134,9 @@ func (a *App) GetSystemLogs() (string, error) {
 		dataDir = platform.MustDataDir()
 	}
 	systemLogDir := filepath.Join(dataDir, "system_logs")
+	if a.systemLogger != nil {
+		a.systemLogger.Flush()
+	}
 	
 	logs.WriteString("=== Application System Logs ===\n")
 	if entries, err := os.ReadDir(systemLogDir); err == nil {
@@ -464,7 +1159,7 @@ func (a *App) GetSystemLogs() (string, error) {
 			// Only include logs from last 24 hours
 			if info.ModTime().After(yesterday) {
 				filePath := filepath.Join(systemLogDir, entry.Name())
-				content, err := os.ReadFile(filePath)
+				content, err := logging.ReadLogFile(filePath)
 				if err == nil {
 					if totalSize+len(content) > maxSize {
 						logs.WriteString(fmt.Sprintf("\n... (remaining logs truncated, limit %dKB reached)\n", maxSize/1024))
@@ -809,8 +1504,17 @@ func (a *App) shutdown(ctx context.Context) {
 	a.LogSystemError("shutdown", "Application is shutting down")
 	
 	// Stop all running processes gracefully
+	a.proxy.Stop()
 	a.pm.StopAll()
 	
 	// Final log
 	a.LogSystemError("shutdown", "Application shutdown complete")
+
+	// Write out buffered log lines
+	for _, logger := range a.loggers {
+		logger.store.Close()
+	}
+	if a.systemLogger != nil {
+		a.systemLogger.Close()
+	}
 }
@@ -30,6 +30,9 @@ export default {
     refresh: 'Refresh',
     search: 'Search...',
     delete: 'Delete',
+    signal: 'Signal',
+    reload: 'Reload',
+    processGroup: 'Process group',
     confirm: 'Confirm',
   },
   tabs: {
@@ -80,6 +83,11 @@ export default {
       stopped: 'Stopped',
       errored: 'Errored',
       starting: 'Starting',
+      completed: 'Completed',
+      failed: 'Failed',
+      backoff: 'Backing off',
+      fatal: 'Crash loop',
+      idle: 'Idle',
     },
     empty: 'No processes found',
   },
@@ -87,6 +95,8 @@ export default {
     processStarted: 'Process started',
     processStopped: 'Process stopped',
     processRestarted: 'Process restarted',
+    processReloaded: 'Process reloaded',
+    signalSent: '{signal} sent',
     processAdded: 'Process added successfully',
     processUpdated: 'Process updated successfully',
     processRemoved: 'Process removed successfully',
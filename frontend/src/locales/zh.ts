@@ -30,6 +30,9 @@ export default {
     refresh: '刷新',
     search: '搜索...',
     delete: '删除',
+    signal: '发送信号',
+    reload: '重载',
+    processGroup: '进程组',
     confirm: '确认',
   },
   tabs: {
@@ -80,6 +83,11 @@ export default {
       stopped: '已停止',
       errored: '失败',
       starting: '启动中',
+      completed: '已完成',
+      failed: '执行失败',
+      backoff: '退避中',
+      fatal: '崩溃循环',
+      idle: '空闲',
     },
     empty: '暂无进程',
   },
@@ -87,6 +95,8 @@ export default {
     processStarted: '进程已启动',
     processStopped: '进程已停止',
     processRestarted: '进程已重启',
+    processReloaded: '进程已重载',
+    signalSent: '已发送 {signal}',
     processAdded: '进程已添加',
     processUpdated: '进程已更新',
     processRemoved: '进程已删除',
@@ -5,7 +5,7 @@ import { process as ProcessModels } from '../../wailsjs/go/models'
 import { i18n } from '../plugins/i18n'
 import { trackError } from '../services/analytics'
 
-export type ProcessStatus = 'running' | 'stopped' | 'errored' | 'starting'
+export type ProcessStatus = 'running' | 'stopped' | 'errored' | 'starting' | 'completed' | 'failed' | 'backoff' | 'fatal' | 'idle'
 
 export interface ProcessItem {
   id: string
@@ -22,6 +22,8 @@ export interface ProcessItem {
   pid: number
   restarts: number
   lastError: string
+  // Full backend definition, so edits keep fields the forms do not cover
+  definition: ProcessModels.Definition
 }
 
 export const useAppStore = defineStore('app', () => {
@@ -72,6 +74,7 @@ export const useAppStore = defineStore('app', () => {
         pid: snap.pid,
         restarts: snap.restarts,
         lastError: snap.lastError || '',
+        definition: snap.definition,
       }))
     } catch (error) {
       const errorMsg = error instanceof Error ? error.message : String(error)
@@ -172,10 +175,34 @@ export const useAppStore = defineStore('app', () => {
     }
   }
 
+  // Send a signal to a process or its whole process group
+  const signalProcess = async (id: string, signal: string, group: boolean) => {
+    try {
+      await AppAPI.SignalProcess(id, signal, group)
+    } catch (error) {
+      const errorMsg = error instanceof Error ? error.message : String(error)
+      trackError(`Failed to signal process: ${errorMsg}`)
+      console.error('Failed to signal process:', error)
+      throw error
+    }
+  }
+
+  // Run the reload action configured for a process
+  const reloadProcess = async (id: string) => {
+    try {
+      await AppAPI.ReloadProcess(id)
+    } catch (error) {
+      const errorMsg = error instanceof Error ? error.message : String(error)
+      trackError(`Failed to reload process: ${errorMsg}`)
+      console.error('Failed to reload process:', error)
+      throw error
+    }
+  }
+
   // Load logs for a specific process
   const loadProcessLogs = async (id: string) => {
     try {
-      const entries = await AppAPI.GetProcessLogs(id)
+      const entries = await AppAPI.GetProcessLogs(id, '')
       logs.value = entries.map((entry: any) => {
         const date = new Date(entry.timestamp)
         const timestamp = date.toLocaleString('zh-CN', {
@@ -198,7 +225,7 @@ export const useAppStore = defineStore('app', () => {
 
   const runningCount = computed(() => processes.value.filter((item) => item.status === 'running').length)
   const stoppedCount = computed(() => processes.value.filter((item) => item.status === 'stopped').length)
-  const failedCount = computed(() => processes.value.filter((item) => item.status === 'errored').length)
+  const failedCount = computed(() => processes.value.filter((item) => item.status === 'errored' || item.status === 'failed' || item.status === 'fatal').length)
 
   // Initialize app settings from backend
   const initSettings = async () => {
@@ -242,6 +269,8 @@ export const useAppStore = defineStore('app', () => {
     startProcess,
     stopProcess,
     restartProcess,
+    signalProcess,
+    reloadProcess,
     loadProcessLogs,
   }
 })
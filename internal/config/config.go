@@ -1,17 +1,29 @@
 package config
 
-import "prochub/internal/process"
+import (
+	"prochub/internal/logging"
+	"prochub/internal/pipeline"
+	"prochub/internal/process"
+	"prochub/internal/proxy"
+)
 
 type AppConfig struct {
-	Locale        string               `json:"locale"`
-	AutoStart     bool                 `json:"autoStart"`
-	LogDir        string               `json:"logDir"`
-	MaxLogLines   int                  `json:"maxLogLines"`
-	MaxLogFiles   int                  `json:"maxLogFiles"`
-	MaxRestart    int                  `json:"maxRestart"`
-	RestartPolicy string               `json:"restartPolicy"`
-	DeviceUUID    string               `json:"deviceUUID"`
-	Processes     []process.Definition `json:"processes"`
+	Locale        string                `json:"locale"`
+	AutoStart     bool                  `json:"autoStart"`
+	LogDir        string                `json:"logDir"`
+	MaxLogLines   int                   `json:"maxLogLines"`
+	MaxLogFiles   int                   `json:"maxLogFiles"`
+	LogRotation   logging.Rotation      `json:"logRotation"` // Size and time based rotation and retention
+	LogSync       logging.SyncPolicy    `json:"logSync"`     // When log lines are forced to disk
+	LogFormat     logging.Format        `json:"logFormat"`   // Text (default) or ndjson
+	MaxRestart    int                   `json:"maxRestart"`
+	RestartPolicy string                `json:"restartPolicy"`
+	OrphanPolicy  string                `json:"orphanPolicy"` // kill, adopt or keep
+	PortRange     [2]int                `json:"portRange"`    // First and last port allocated to processes
+	DeviceUUID    string                `json:"deviceUUID"`
+	Processes     []process.Definition  `json:"processes"`
+	Pipelines     []pipeline.Definition `json:"pipelines"`
+	Proxy         proxy.Config          `json:"proxy"` // Embedded reverse proxy
 }
 
 func DefaultConfig() AppConfig {
@@ -23,5 +35,7 @@ func DefaultConfig() AppConfig {
 		MaxLogFiles:   5,
 		MaxRestart:    5,
 		RestartPolicy: "on_failure",
+		OrphanPolicy:  "kill",
+		PortRange:     [2]int{process.DefaultPortRangeStart, process.DefaultPortRangeEnd},
 	}
 }
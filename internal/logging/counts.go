@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// countMinutes is how far back a LevelCounter remembers
+const countMinutes = 60
+
+// LevelCount is the number of errors and warnings logged in a minute
+type LevelCount struct {
+	Minute   time.Time `json:"minute"`
+	Errors   int       `json:"errors"` // Error and fatal entries
+	Warnings int       `json:"warnings"`
+}
+
+// LevelCounter counts errors and warnings per minute over the last hour.
+// The zero value is ready to use.
+type LevelCounter struct {
+	mu      sync.Mutex
+	buckets [countMinutes]LevelCount // by minute of the hour
+}
+
+// Add counts an entry of level logged at
+func (c *LevelCounter) Add(level string, at time.Time) {
+	rank := levelRanks[level]
+	if rank < levelRanks[LevelWarn] {
+		return
+	}
+	minute := at.Truncate(time.Minute)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &c.buckets[minuteIndex(minute)]
+	if !b.Minute.Equal(minute) {
+		if minute.Before(b.Minute) {
+			// Older than the hour the bucket now holds
+			return
+		}
+		*b = LevelCount{Minute: minute}
+	}
+	if rank >= levelRanks[LevelError] {
+		b.Errors++
+	} else {
+		b.Warnings++
+	}
+}
+
+// Counts returns the counts of the hour up to now, a minute each, oldest
+// first. Minutes without errors or warnings are included with zeros.
+func (c *LevelCounter) Counts(now time.Time) []LevelCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := now.Truncate(time.Minute)
+	counts := make([]LevelCount, countMinutes)
+	for i := range counts {
+		minute := current.Add(time.Duration(i-countMinutes+1) * time.Minute)
+		if b := c.buckets[minuteIndex(minute)]; b.Minute.Equal(minute) {
+			counts[i] = b
+		} else {
+			counts[i] = LevelCount{Minute: minute}
+		}
+	}
+	return counts
+}
+
+func minuteIndex(minute time.Time) int {
+	return int(minute.Unix()/60) % countMinutes
+}
@@ -0,0 +1,159 @@
+package logging
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Entry is a line of process output. Seq is kept in memory and in NDJSON
+// files only; entries read back from text files have none.
+type Entry struct {
+	Seq       uint64         `json:"seq,omitempty"` // Order of the line within its process, across streams
+	Timestamp time.Time      `json:"timestamp"`
+	Stream    string         `json:"stream"`
+	Line      string         `json:"line"`
+	Level     string         `json:"level,omitempty"`   // Severity, see Classifier
+	Message   string         `json:"message,omitempty"` // From a JSON log line
+	Fields    map[string]any `json:"fields,omitempty"`  // Other keys of a JSON log line
+}
+
+// Format is the on-disk format of a RollingStore
+type Format string
+
+const (
+	FormatText   Format = ""       // timestamp stream line, without Seq
+	FormatNDJSON Format = "ndjson" // One JSON entry per line
+)
+
+// Keys JSON loggers commonly use for the level and the message, by priority
+var (
+	levelKeys   = []string{"level", "lvl", "severity", "log.level", "loglevel"}
+	messageKeys = []string{"msg", "message", "@message", "event"}
+)
+
+// ParseJSON fills Level, Message and Fields from a line that is a JSON
+// object, as written by structured loggers. It reports whether it was one.
+func (e *Entry) ParseJSON() bool {
+	line := strings.TrimSpace(e.Line)
+	if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+		return false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return false
+	}
+	for _, key := range levelKeys {
+		switch value := fields[key].(type) {
+		case string:
+			e.Level = strings.ToLower(value)
+		case float64:
+			e.Level = numericLevel(value)
+		default:
+			continue
+		}
+		delete(fields, key)
+		break
+	}
+	for _, key := range messageKeys {
+		if value, ok := fields[key].(string); ok {
+			e.Message = value
+			delete(fields, key)
+			break
+		}
+	}
+	if len(fields) > 0 {
+		e.Fields = fields
+	}
+	return true
+}
+
+// numericLevel names the numeric levels of pino and bunyan
+func numericLevel(n float64) string {
+	switch {
+	case n >= 60:
+		return "fatal"
+	case n >= 50:
+		return "error"
+	case n >= 40:
+		return "warn"
+	case n >= 30:
+		return "info"
+	case n >= 20:
+		return "debug"
+	}
+	return "trace"
+}
+
+// Field returns a field of a JSON log line as text: level, message, a key
+// of Fields or a dotted path into nested objects
+func (e Entry) Field(name string) (string, bool) {
+	switch name {
+	case "level":
+		return e.Level, e.Level != ""
+	case "message":
+		return e.Message, e.Message != ""
+	}
+	// Keys may contain dots themselves
+	value, ok := e.Fields[name]
+	if !ok {
+		value = e.Fields
+		for _, key := range strings.Split(name, ".") {
+			object, isObject := value.(map[string]any)
+			if !isObject {
+				return "", false
+			}
+			if value, ok = object[key]; !ok {
+				return "", false
+			}
+		}
+	}
+	if text, ok := value.(string); ok {
+		return text, true
+	}
+	data, _ := json.Marshal(value)
+	return string(data), true
+}
+
+// formatEntry renders an entry as a line of a log file, without the newline.
+// In text files the lines of a multi-line entry after the first are
+// indented by a tab, which no entry line starts with.
+func formatEntry(e Entry, format Format) string {
+	if format == FormatNDJSON {
+		data, err := json.Marshal(e)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return e.Timestamp.Format(time.RFC3339Nano) + " " + e.Stream + " " + strings.ReplaceAll(e.Line, "\n", "\n"+continuationIndent)
+}
+
+// continuationIndent starts the lines of a text log file that continue the
+// entry before
+const continuationIndent = "\t"
+
+// ParseLine parses a line of a log file in either format, so files keep
+// reading after the format changes. The line of a multi-line text entry
+// includes its indented continuation lines.
+func ParseLine(line string) (Entry, bool) {
+	if strings.HasPrefix(line, "{") {
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.Timestamp.IsZero() {
+			return Entry{}, false
+		}
+		return e, true
+	}
+
+	stamp, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return Entry{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return Entry{}, false
+	}
+	stream, text, _ := strings.Cut(rest, " ")
+	e := Entry{Timestamp: ts, Stream: stream, Line: strings.ReplaceAll(text, "\n"+continuationIndent, "\n")}
+	e.ParseJSON()
+	return e, true
+}
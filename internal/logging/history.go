@@ -0,0 +1,214 @@
+package logging
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page is a run of consecutive entries of a process, oldest first
+type Page struct {
+	Entries []Entry `json:"entries"`
+	// Cursor continues the page: it marks the first entry for Before and
+	// the last one for Since, or is the cursor asked for when empty
+	Cursor string `json:"cursor"`
+	More   bool   `json:"more"` // Entries remain beyond the page
+}
+
+// History reads the entries of a process from its hub and, for what the
+// hub no longer holds, from its log files. Entries are told apart by their
+// timestamps, which must be unique within the process.
+type History struct {
+	Hub    *StreamHub
+	Source Source
+}
+
+// Cursor marks an entry for History.Before and History.Since
+func Cursor(e Entry) string {
+	return strconv.FormatInt(e.Timestamp.UnixNano(), 10)
+}
+
+func parseCursor(cursor string) (time.Time, error) {
+	if cursor == "" {
+		return time.Time{}, nil
+	}
+	ns, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+	return time.Unix(0, ns), nil
+}
+
+// Before returns up to limit entries older than the one cursor marks, or
+// the newest ones when cursor is empty
+func (h History) Before(cursor string, limit int) (Page, error) {
+	before, err := parseCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	var recent []Entry
+	if h.Hub != nil {
+		recent = h.Hub.Snapshot()
+	}
+	end := len(recent)
+	if !before.IsZero() {
+		end = sort.Search(len(recent), func(i int) bool { return !recent[i].Timestamp.Before(before) })
+	}
+	start := max(end-limit, 0)
+	page := Page{Entries: recent[start:end], Cursor: cursor, More: start > 0}
+
+	if !page.More && h.Source.Dir != "" {
+		// The rest comes from the files, older than anything in the hub
+		if len(recent) > 0 && (before.IsZero() || recent[0].Timestamp.Before(before)) {
+			before = recent[0].Timestamp
+		}
+		older, more, err := readBefore(h.Source, before, limit-len(page.Entries))
+		if err != nil {
+			return Page{}, err
+		}
+		page.Entries = append(older, page.Entries...)
+		page.More = more
+	}
+	if len(page.Entries) > 0 {
+		page.Cursor = Cursor(page.Entries[0])
+	}
+	return page, nil
+}
+
+// Since returns up to limit entries newer than the one cursor marks, or the
+// oldest ones when cursor is empty. Entries the hub no longer holds are
+// read from the files.
+func (h History) Since(cursor string, limit int) (Page, error) {
+	since, err := parseCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	var recent []Entry
+	if h.Hub != nil {
+		recent = h.Hub.Snapshot()
+	}
+	page := Page{Entries: make([]Entry, 0), Cursor: cursor}
+	inHub := len(recent) > 0 && !since.IsZero() && !since.Before(recent[0].Timestamp)
+	if !inHub && h.Source.Dir != "" {
+		older, more, err := readSince(h.Source, since, limit)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Entries, page.More = older, more
+		if len(older) > 0 {
+			since = older[len(older)-1].Timestamp
+		}
+	}
+	if !page.More {
+		// Go on with what the hub holds past the files
+		start := sort.Search(len(recent), func(i int) bool { return recent[i].Timestamp.After(since) })
+		end := min(start+limit-len(page.Entries), len(recent))
+		page.Entries = append(page.Entries, recent[start:end]...)
+		page.More = end < len(recent)
+	}
+	if len(page.Entries) > 0 {
+		page.Cursor = Cursor(page.Entries[len(page.Entries)-1])
+	}
+	return page, nil
+}
+
+// readBefore reads up to limit entries older than before, or the newest
+// ones when it is zero, from the files of source
+func readBefore(source Source, before time.Time, limit int) ([]Entry, bool, error) {
+	segments, err := listSegments(source.Dir)
+	if err != nil {
+		return nil, false, err
+	}
+	var entries []Entry
+	for i := len(segments) - 1; i >= 0; i-- {
+		if len(entries) == limit {
+			return entries, true, nil
+		}
+		// Entries are in time order, so a file starting at or after before
+		// holds none of them
+		if !before.IsZero() {
+			if first, ok := firstEntry(source.Dir, segments[i].name); ok && !first.Timestamp.Before(before) {
+				continue
+			}
+		}
+		fileEntries, err := readEntries(source, segments[i].name)
+		if err != nil {
+			return nil, false, err
+		}
+		if !before.IsZero() {
+			end := sort.Search(len(fileEntries), func(i int) bool { return !fileEntries[i].Timestamp.Before(before) })
+			fileEntries = fileEntries[:end]
+		}
+		if room := limit - len(entries); len(fileEntries) > room {
+			return append(fileEntries[len(fileEntries)-room:], entries...), true, nil
+		}
+		entries = append(fileEntries, entries...)
+	}
+	return entries, false, nil
+}
+
+// readSince reads up to limit entries newer than since, or the oldest ones
+// when it is zero, from the files of source
+func readSince(source Source, since time.Time, limit int) ([]Entry, bool, error) {
+	segments, err := listSegments(source.Dir)
+	if err != nil {
+		return nil, false, err
+	}
+	entries := make([]Entry, 0)
+	for i, seg := range segments {
+		// Entries of a file were written before the next file was created
+		if i+1 < len(segments) && !since.IsZero() && !segmentStart(segments[i+1].name).Add(time.Second).After(since) {
+			continue
+		}
+		if len(entries) == limit {
+			return entries, true, nil
+		}
+		fileEntries, err := readEntries(source, seg.name)
+		if err != nil {
+			return nil, false, err
+		}
+		start := sort.Search(len(fileEntries), func(i int) bool { return fileEntries[i].Timestamp.After(since) })
+		fileEntries = fileEntries[start:]
+		if room := limit - len(entries); len(fileEntries) > room {
+			return append(entries, fileEntries[:room]...), true, nil
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, false, nil
+}
+
+// readEntries parses a log file of source, oldest first, with levels
+// assigned and multi-line entries joined. A file removed by retention
+// meanwhile reads as empty.
+func readEntries(source Source, name string) ([]Entry, error) {
+	lines, err := readLines(source.Dir, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for i+1 < len(lines) && strings.HasPrefix(lines[i+1], continuationIndent) {
+			i++
+			line += "\n" + lines[i]
+		}
+		if entry, ok := ParseLine(line); ok {
+			source.Levels.Classify(&entry)
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHistoryPages(t *testing.T) {
+	dir := t.TempDir()
+	// Four lines a file, so the history spans several files
+	store := NewRollingStore(dir, 4, 0)
+	hub := NewStreamHub(3)
+	base := time.Now().Add(-time.Hour)
+	var want []string
+	for i := 0; i < 10; i++ {
+		line := "line " + strconv.Itoa(i)
+		if i == 5 {
+			line += "\n\tat a stack frame"
+		}
+		e := Entry{Timestamp: base.Add(time.Duration(i) * time.Millisecond), Stream: "stdout", Line: line}
+		if err := store.Append(e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		hub.Push(e)
+		want = append(want, line)
+	}
+	store.Close()
+	history := History{Hub: hub, Source: Source{Dir: dir}}
+
+	// Scroll back from the end in pages of four
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, err := history.Before(cursor, 4)
+		if err != nil {
+			t.Fatalf("Before failed: %v", err)
+		}
+		var lines []string
+		for _, e := range page.Entries {
+			lines = append(lines, e.Line)
+		}
+		got = append(lines, got...)
+		cursor = page.Cursor
+		if !page.More || pages > 5 {
+			break
+		}
+	}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Fatalf("backwards = %q, want %q", got, want)
+	}
+
+	// Read forward from the start, across the files into the hub
+	got, cursor = nil, ""
+	for pages := 0; ; pages++ {
+		page, err := history.Since(cursor, 4)
+		if err != nil {
+			t.Fatalf("Since failed: %v", err)
+		}
+		for _, e := range page.Entries {
+			got = append(got, e.Line)
+		}
+		cursor = page.Cursor
+		if !page.More || pages > 5 {
+			break
+		}
+	}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Fatalf("forwards = %q, want %q", got, want)
+	}
+
+	// Following from the end finds nothing new until a line arrives
+	page, err := history.Since(cursor, 4)
+	if err != nil || len(page.Entries) != 0 || page.Cursor != cursor {
+		t.Fatalf("expected an empty page at the end, got %+v, %v", page, err)
+	}
+	next := Entry{Timestamp: base.Add(time.Second), Stream: "stderr", Line: "new"}
+	hub.Push(next)
+	if page, _ = history.Since(cursor, 4); len(page.Entries) != 1 || page.Cursor != Cursor(next) {
+		t.Fatalf("expected the new entry, got %+v", page)
+	}
+
+	if _, err := history.Before("not a cursor", 4); err != ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
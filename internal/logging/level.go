@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Levels of log entries, by increasing severity
+const (
+	LevelTrace = "trace"
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+var ErrUnknownLevel = errors.New("unknown log level")
+
+var levelRanks = map[string]int{
+	LevelTrace: 1,
+	LevelDebug: 2,
+	LevelInfo:  3,
+	LevelWarn:  4,
+	LevelError: 5,
+	LevelFatal: 6,
+}
+
+// levelNames maps the ways loggers spell levels to the levels above
+var levelNames = map[string]string{
+	"trace": LevelTrace, "trc": LevelTrace,
+	"debug": LevelDebug, "dbg": LevelDebug, "verbose": LevelDebug,
+	"info": LevelInfo, "inf": LevelInfo, "information": LevelInfo, "notice": LevelInfo,
+	"warn": LevelWarn, "wrn": LevelWarn, "warning": LevelWarn,
+	"error": LevelError, "err": LevelError, "eror": LevelError,
+	"fatal": LevelFatal, "ftl": LevelFatal, "panic": LevelFatal, "crit": LevelFatal, "critical": LevelFatal,
+	"alert": LevelFatal, "emerg": LevelFatal, "emergency": LevelFatal,
+}
+
+var (
+	// ansiEscape matches the color codes loggers wrap levels in
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// logfmtLevel matches the level of a logfmt line, e.g. level=warn
+	logfmtLevel = regexp.MustCompile(`(?:^|\s)(?:level|lvl|severity)="?([A-Za-z]+)`)
+)
+
+const (
+	// prefixTokens is how many words at the start of a line are looked at
+	// for a level, to get past timestamps and logger names
+	prefixTokens = 6
+	prefixBytes  = 160
+)
+
+// NormalizeLevel maps a level as loggers write it, such as WARNING or err,
+// to one of the levels above. It returns "" for names it does not know.
+func NormalizeLevel(name string) string {
+	return levelNames[strings.ToLower(name)]
+}
+
+// LevelAtLeast reports whether level is as severe as min. Every entry is
+// when min is empty or unknown; entries without a known level are not
+// otherwise.
+func LevelAtLeast(level, min string) bool {
+	want := levelRanks[NormalizeLevel(min)]
+	return want == 0 || levelRanks[NormalizeLevel(level)] >= want
+}
+
+// FilterLevel returns the entries as severe as min
+func FilterLevel(entries []Entry, min string) []Entry {
+	if levelRanks[NormalizeLevel(min)] == 0 {
+		return entries
+	}
+	filtered := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if LevelAtLeast(e.Level, min) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// Classifier assigns levels to entries. A nil Classifier only knows the
+// common formats.
+type Classifier struct {
+	pattern *regexp.Regexp
+	group   int // submatch holding the level
+}
+
+// NewClassifier returns a classifier trying pattern before the common
+// formats. The level is taken from the group named level, else from the
+// first group, else from the whole match. An empty pattern returns nil.
+func NewClassifier(pattern string) (*Classifier, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	group := re.SubexpIndex("level")
+	if group < 0 {
+		group = min(re.NumSubexp(), 1)
+	}
+	return &Classifier{pattern: re, group: group}, nil
+}
+
+// Classify sets the level of an entry whose line went through ParseJSON.
+// The level of a JSON line is normalized; other lines are matched against
+// the pattern, then against common prefixes such as ERROR, [info] or
+// warning: and logfmt level keys. Lines matching none keep no level.
+func (c *Classifier) Classify(e *Entry) {
+	if e.Level != "" {
+		if level := NormalizeLevel(e.Level); level != "" {
+			e.Level = level
+		}
+		return
+	}
+	line := e.Line
+	if strings.IndexByte(line, 0x1b) >= 0 {
+		line = ansiEscape.ReplaceAllString(line, "")
+	}
+	if c != nil {
+		if m := c.pattern.FindStringSubmatch(line); m != nil {
+			if level := NormalizeLevel(strings.TrimSpace(m[c.group])); level != "" {
+				e.Level = level
+				return
+			}
+		}
+	}
+	e.Level = prefixLevel(line)
+}
+
+// prefixLevel finds a level among the first words of a line, or of the
+// first line of a multi-line entry. Bracketed words and words followed by a
+// colon match in any case, bare words only in upper case, so that prose
+// mentioning an error does not count.
+func prefixLevel(line string) string {
+	line, _, _ = strings.Cut(line, "\n")
+	head := line[:min(len(line), prefixBytes)]
+	for i, token := range strings.Fields(head) {
+		if i == prefixTokens {
+			break
+		}
+		bracketed := strings.IndexAny(token[:1], "[(<") == 0
+		token = strings.TrimLeft(token, "[(<")
+		end := strings.IndexFunc(token, func(r rune) bool { return !unicode.IsLetter(r) })
+		word, rest := token, ""
+		if end >= 0 {
+			word, rest = token[:end], token[end:]
+		}
+		level := NormalizeLevel(word)
+		if level == "" {
+			continue
+		}
+		closed := rest != "" && strings.IndexAny(rest[:1], "])>:|") == 0
+		if (bracketed && closed) || strings.HasPrefix(rest, ":") || (rest == "" && word == strings.ToUpper(word)) {
+			return level
+		}
+	}
+	if m := logfmtLevel.FindStringSubmatch(line); m != nil {
+		return NormalizeLevel(m[1])
+	}
+	return ""
+}
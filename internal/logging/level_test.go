@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	custom, err := NewClassifier(`^\S+ <(?P<level>\w+)> `)
+	if err != nil {
+		t.Fatalf("NewClassifier failed: %v", err)
+	}
+
+	cases := []struct {
+		levels *Classifier
+		line   string
+		want   string
+	}{
+		{nil, `{"level":"WARNING","msg":"disk"}`, LevelWarn},
+		{nil, `{"severity":"critical"}`, LevelFatal},
+		{nil, `2026/01/02 10:00:00 [ERROR] db down`, LevelError},
+		{nil, `2026-01-02 10:00:00,123 - app.db - WARN - slow query`, LevelWarn},
+		{nil, `[info] listening on :8080`, LevelInfo},
+		{nil, "\x1b[31mERROR\x1b[0m boom", LevelError},
+		{nil, `error: could not compile`, LevelError},
+		{nil, `panic: runtime error`, LevelFatal},
+		{nil, `time=10:00 level=debug msg="tick"`, LevelDebug},
+		{nil, `no error occurred`, ""},
+		{nil, `ERR_CONNECTION_REFUSED while fetching`, ""},
+		{custom, `10:00:00 <err> bad thing`, LevelError},
+		{custom, `10:00:00 INFO <nope> falls back to prefixes`, LevelInfo},
+	}
+	for _, c := range cases {
+		e := Entry{Line: c.line}
+		e.ParseJSON()
+		c.levels.Classify(&e)
+		if e.Level != c.want {
+			t.Errorf("Classify(%q) = %q, want %q", c.line, e.Level, c.want)
+		}
+	}
+
+	entries := []Entry{{Level: LevelInfo}, {Level: LevelWarn}, {}, {Level: LevelFatal}}
+	if got := FilterLevel(entries, "warning"); len(got) != 2 {
+		t.Errorf("FilterLevel kept %+v, want warn and fatal", got)
+	}
+	if got := FilterLevel(entries, ""); len(got) != 4 {
+		t.Errorf("FilterLevel without a level kept %d entries, want 4", len(got))
+	}
+}
+
+func TestLevelCounter(t *testing.T) {
+	var counter LevelCounter
+	now := time.Date(2026, 1, 2, 10, 30, 15, 0, time.UTC)
+	counter.Add(LevelError, now)
+	counter.Add(LevelFatal, now)
+	counter.Add(LevelWarn, now.Add(-time.Minute))
+	counter.Add(LevelInfo, now)
+	// An hour ago shares the bucket of now and must not be counted with it
+	counter.Add(LevelWarn, now.Add(-time.Hour))
+
+	counts := counter.Counts(now)
+	if len(counts) != countMinutes {
+		t.Fatalf("expected %d minutes, got %d", countMinutes, len(counts))
+	}
+	last, previous := counts[len(counts)-1], counts[len(counts)-2]
+	if !last.Minute.Equal(now.Truncate(time.Minute)) || last.Errors != 2 || last.Warnings != 0 {
+		t.Errorf("unexpected current minute %+v", last)
+	}
+	if previous.Errors != 0 || previous.Warnings != 1 {
+		t.Errorf("unexpected previous minute %+v", previous)
+	}
+}
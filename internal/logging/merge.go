@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"sort"
+	"time"
+)
+
+// Feed is a process in a merged log view: its recent entries in Hub and
+// its older ones in the log files of Source
+type Feed struct {
+	ID     string
+	Name   string
+	Hub    *StreamHub
+	Source Source
+}
+
+// MergedEntry is an entry of a merged log view, tagged with its process
+type MergedEntry struct {
+	Entry
+	ProcessID   string `json:"processId"`
+	ProcessName string `json:"processName"`
+}
+
+// Merge returns the newest q.Limit entries of the feeds matching q, oldest
+// first. Entries come from the hub of a feed, then from its files for the
+// time before the hub when the hub holds too few. The cursor of q is not
+// used.
+func Merge(feeds []Feed, q Query) ([]MergedEntry, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	match, err := Matcher(q)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make([]MergedEntry, 0)
+	for _, feed := range feeds {
+		var recent []MergedEntry
+		var oldest time.Time
+		if feed.Hub != nil {
+			entries := feed.Hub.Snapshot()
+			if len(entries) > 0 {
+				oldest = entries[0].Timestamp
+			}
+			for _, e := range entries {
+				if inRange(e, q) && match(e) {
+					recent = append(recent, MergedEntry{Entry: e, ProcessID: feed.ID, ProcessName: feed.Name})
+				}
+			}
+		}
+		if len(recent) < q.Limit && feed.Source.Dir != "" {
+			// Only look on disk for what the hub no longer holds
+			history := q
+			history.Cursor = ""
+			history.Limit = q.Limit - len(recent)
+			if !oldest.IsZero() && (q.Until == nil || oldest.Before(*q.Until)) {
+				until := oldest.Add(-time.Nanosecond)
+				history.Until = &until
+			}
+			result, err := Search(map[string]Source{feed.ID: feed.Source}, history)
+			if err != nil {
+				return nil, err
+			}
+			// Matches are newest first
+			for i := len(result.Matches) - 1; i >= 0; i-- {
+				merged = append(merged, MergedEntry{Entry: result.Matches[i].Entry, ProcessID: feed.ID, ProcessName: feed.Name})
+			}
+		}
+		merged = append(merged, recent...)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	if len(merged) > q.Limit {
+		merged = merged[len(merged)-q.Limit:]
+	}
+	return merged, nil
+}
+
+// inRange reports whether an entry falls within the time range of q
+func inRange(e Entry, q Query) bool {
+	return (q.Since == nil || !e.Timestamp.Before(*q.Since)) && (q.Until == nil || !e.Timestamp.After(*q.Until))
+}
@@ -4,23 +4,72 @@ import (
 	"bufio"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 )
 
-type Entry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Stream    string    `json:"stream"`
-	Line      string    `json:"line"`
+// fileTimeLayout names log files after their creation time, so that name
+// order is time order
+const fileTimeLayout = "20060102_150405"
+
+const (
+	writeBufferSize = 64 << 10
+	// flushInterval bounds how long a line waits in the buffer
+	flushInterval = time.Second
+	// maintainInterval is how often retention runs while lines are written,
+	// for age limits passed without a rotation
+	maintainInterval = time.Hour
+)
+
+// SyncPolicy sets when written lines are forced to disk with fsync
+type SyncPolicy string
+
+const (
+	SyncNever    SyncPolicy = ""         // Leave it to the OS
+	SyncInterval SyncPolicy = "interval" // On every periodic flush
+	SyncAlways   SyncPolicy = "always"   // After every line, at a large cost
+)
+
+// RotateInterval starts a new file on time boundaries
+type RotateInterval string
+
+const (
+	RotateNever  RotateInterval = ""
+	RotateHourly RotateInterval = "hourly"
+	RotateDaily  RotateInterval = "daily"
+)
+
+// Rotation sets when a RollingStore starts a new file, on top of its line
+// limit, and which old files it keeps, on top of its file limit. Zero values
+// disable a limit.
+type Rotation struct {
+	MaxFileMB  int            `json:"maxFileMB"`  // Rotate once the file reaches this size
+	Interval   RotateInterval `json:"interval"`   // Rotate every hour or day
+	MaxTotalMB int            `json:"maxTotalMB"` // Delete the oldest files beyond this total
+	MaxAgeDays int            `json:"maxAgeDays"` // Delete files older than this
+	Compress   bool           `json:"compress"`   // Gzip rotated files
 }
 
 type RollingStore struct {
-	mu        sync.Mutex
-	dir       string
-	maxLines  int
-	maxFiles  int
-	filename  string
-	lineCount int
+	mu         sync.Mutex
+	dir        string
+	maxLines   int
+	maxFiles   int
+	rotation   Rotation
+	sync       SyncPolicy
+	format     Format
+	filename   string
+	lineCount  int
+	size       int64
+	opened     time.Time
+	file       *os.File // open while lines are being written to filename
+	writer     *bufio.Writer
+	flusher    *time.Timer // pending flush of buffered lines, nil when clean
+	maintainer *time.Timer // next periodic retention run, nil while closed
+	closed     bool        // set by Close, after which lines are dropped
+
+	maintaining sync.Mutex // serializes retention and compression runs
 }
 
 func NewRollingStore(dir string, maxLines, maxFiles int) *RollingStore {
@@ -31,79 +80,259 @@ func NewRollingStore(dir string, maxLines, maxFiles int) *RollingStore {
 	}
 }
 
+// SetRotation changes the size, time and retention limits. Rotation applies
+// from the next line on, retention right away.
+func (r *RollingStore) SetRotation(rotation Rotation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotation = rotation
+	go r.maintain()
+}
+
+// SetSync changes when lines are forced to disk
+func (r *RollingStore) SetSync(policy SyncPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sync = policy
+}
+
+// SetFormat changes the format new lines are written in
+func (r *RollingStore) SetFormat(format Format) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.format = format
+}
+
+// Append buffers a line for the current file, which is kept open. Lines
+// reach the file once the buffer fills, within flushInterval, or on Flush,
+// rotation and Close.
 func (r *RollingStore) Append(entry Entry) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
 
+	now := time.Now()
+	if r.filename != "" && r.rotation.Interval != RotateNever && intervalStart(r.rotation.Interval, now) != intervalStart(r.rotation.Interval, r.opened) {
+		r.rotate()
+	}
 	if r.filename == "" {
 		if err := os.MkdirAll(r.dir, 0o755); err != nil {
 			return err
 		}
-		r.filename = filepath.Join(r.dir, time.Now().Format("20060102_150405")+".log")
+		r.filename = newFileName(r.dir, now)
+		r.opened = now
+		r.size = 0
+		if info, err := os.Stat(r.filename); err == nil {
+			r.size = info.Size()
+		}
+	}
+	if r.maintainer == nil {
+		// Apply retention to files left by earlier runs as well
+		go r.maintain()
+		r.maintainer = time.AfterFunc(maintainInterval, r.maintainPeriodically)
 	}
 
-	file, err := os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-	if err != nil {
-		return err
+	if r.file == nil {
+		file, err := os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		r.file = file
+		if r.writer == nil {
+			r.writer = bufio.NewWriterSize(file, writeBufferSize)
+		} else {
+			r.writer.Reset(file)
+		}
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	_, err = writer.WriteString(entry.Timestamp.Format(time.RFC3339) + " " + entry.Stream + " " + entry.Line + "\n")
+	n, err := r.writer.WriteString(formatEntry(entry, r.format) + "\n")
 	if err != nil {
 		return err
 	}
-	if err := writer.Flush(); err != nil {
-		return err
+	if r.sync == SyncAlways {
+		if err := r.flush(true); err != nil {
+			return err
+		}
+	} else if r.flusher == nil {
+		r.flusher = time.AfterFunc(flushInterval, r.flushBuffered)
 	}
 
 	r.lineCount++
-	if r.lineCount >= r.maxLines {
+	r.size += int64(n)
+	if (r.maxLines > 0 && r.lineCount >= r.maxLines) || (r.rotation.MaxFileMB > 0 && r.size >= int64(r.rotation.MaxFileMB)<<20) {
 		r.rotate()
 	}
 	return nil
 }
 
+// Flush writes buffered lines to the file, for readers of the file
+func (r *RollingStore) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flush(r.sync != SyncNever)
+}
+
+// Close flushes and closes the current file. Lines appended afterwards are
+// dropped.
+func (r *RollingStore) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.maintainer != nil {
+		r.maintainer.Stop()
+		r.maintainer = nil
+	}
+	return r.closeFile()
+}
+
+// flushBuffered runs flushInterval after a line was buffered
+func (r *RollingStore) flushBuffered() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flusher = nil
+	_ = r.flush(r.sync == SyncInterval)
+}
+
+// flush writes out the buffer, then fsyncs if sync is set. Callers must
+// hold r.mu.
+func (r *RollingStore) flush(sync bool) error {
+	if r.flusher != nil {
+		r.flusher.Stop()
+		r.flusher = nil
+	}
+	if r.file == nil {
+		return nil
+	}
+	if err := r.writer.Flush(); err != nil {
+		return err
+	}
+	if sync {
+		return r.file.Sync()
+	}
+	return nil
+}
+
+// closeFile flushes and closes the current file. Callers must hold r.mu.
+func (r *RollingStore) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.flush(r.sync != SyncNever)
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file = nil
+	return err
+}
+
 func (r *RollingStore) rotate() {
 	if r.filename == "" {
 		return
 	}
+	_ = r.closeFile()
 	r.filename = ""
 	r.lineCount = 0
-	_ = r.cleanup()
+	r.size = 0
+	// The file just closed may now be compressed or deleted
+	go r.maintain()
 }
 
-func (r *RollingStore) cleanup() error {
-	entries, err := os.ReadDir(r.dir)
-	if err != nil {
-		return err
+// newFileName names a new log file after now, adding a counter when a file
+// was already started within the same second
+func newFileName(dir string, now time.Time) string {
+	base := now.Format(fileTimeLayout)
+	name := filepath.Join(dir, base+".log")
+	for i := 1; fileExists(name) || fileExists(name+gzipSuffix); i++ {
+		name = filepath.Join(dir, base+"_"+strconv.Itoa(i)+".log")
 	}
-	if len(entries) <= r.maxFiles {
-		return nil
+	return name
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// intervalStart returns the start of the hour or day t falls in
+func intervalStart(interval RotateInterval, t time.Time) time.Time {
+	y, m, d := t.Date()
+	if interval == RotateHourly {
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// maintainPeriodically runs maintain every maintainInterval until Close
+func (r *RollingStore) maintainPeriodically() {
+	r.maintain()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maintainer != nil {
+		r.maintainer.Reset(maintainInterval)
 	}
+}
+
+// maintain compresses rotated files and deletes the oldest ones beyond the
+// file count, total size and age limits. The file being written is left
+// alone, as are newer ones started since.
+func (r *RollingStore) maintain() {
+	r.maintaining.Lock()
+	defer r.maintaining.Unlock()
 
-	type fileInfo struct {
-		name string
-		mod  time.Time
+	r.mu.Lock()
+	current := filepath.Base(r.filename)
+	if r.filename == "" {
+		// No file is being written, and the next one is named after a
+		// later time than every file there is now
+		current = time.Now().Format(fileTimeLayout)
 	}
-	files := make([]fileInfo, 0, len(entries))
-	for _, entry := range entries {
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-		files = append(files, fileInfo{name: entry.Name(), mod: info.ModTime()})
+	maxFiles, rotation := r.maxFiles, r.rotation
+	r.mu.Unlock()
+
+	segments, err := listSegments(r.dir)
+	if err != nil {
+		return
 	}
 
-	for len(files) > r.maxFiles {
-		oldest := 0
-		for i := 1; i < len(files); i++ {
-			if files[i].mod.Before(files[oldest].mod) {
-				oldest = i
+	if rotation.Compress {
+		for i, seg := range segments {
+			if seg.name >= current || seg.compressed {
+				continue
+			}
+			if err := compressFile(filepath.Join(r.dir, seg.file)); err == nil {
+				segments[i].file = seg.name + gzipSuffix
+				segments[i].compressed = true
+				if info, err := os.Stat(filepath.Join(r.dir, segments[i].file)); err == nil {
+					segments[i].size = info.Size()
+				}
 			}
 		}
-		_ = os.Remove(filepath.Join(r.dir, files[oldest].name))
-		files = append(files[:oldest], files[oldest+1:]...)
 	}
-	return nil
+
+	var total int64
+	for _, seg := range segments {
+		total += seg.size
+	}
+	cutoff := time.Time{}
+	if rotation.MaxAgeDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -rotation.MaxAgeDays)
+	}
+	// Segments are oldest first
+	for i, seg := range segments {
+		if seg.name >= current {
+			break
+		}
+		remaining := len(segments) - i
+		overCount := maxFiles > 0 && remaining > maxFiles
+		overSize := rotation.MaxTotalMB > 0 && total > int64(rotation.MaxTotalMB)<<20
+		tooOld := !cutoff.IsZero() && seg.modTime.Before(cutoff)
+		if !overCount && !overSize && !tooOld {
+			continue
+		}
+		if os.Remove(filepath.Join(r.dir, seg.file)) == nil {
+			total -= seg.size
+		}
+	}
 }
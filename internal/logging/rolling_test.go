@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitSegments polls until the store's maintenance settles on want
+func waitSegments(t *testing.T, dir string, want func([]segment) bool) []segment {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		segments, err := listSegments(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want(segments) {
+			return segments
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected segments %+v", segments)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRollingStoreSizeRotationAndCompression(t *testing.T) {
+	dir := t.TempDir()
+	store := NewRollingStore(dir, 0, 3)
+	store.SetRotation(Rotation{MaxFileMB: 1, Compress: true})
+
+	line := strings.Repeat("x", 100<<10)
+	for i := 0; i < 45; i++ {
+		if err := store.Append(Entry{Timestamp: time.Now(), Stream: "stdout", Line: line}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	// Five files of about 1MB were started; three are kept and all but the
+	// current one are compressed
+	segments := waitSegments(t, dir, func(segments []segment) bool {
+		return len(segments) == 3 && segments[0].compressed && segments[1].compressed && !segments[2].compressed
+	})
+	for _, seg := range segments[:2] {
+		if seg.size >= 1<<20 {
+			t.Errorf("%s was not compressed: %d bytes", seg.file, seg.size)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("expected only the kept segments on disk, got %d files", len(entries))
+	}
+
+	result, err := Search(map[string]Source{"proc": {Dir: dir}}, Query{Limit: 1000})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Matches) < 20 || result.Matches[0].Line != line {
+		t.Fatalf("expected to read back the compressed segments, got %d matches", len(result.Matches))
+	}
+}
+
+func TestRollingStoreRetentionWithoutOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		name := base.Add(time.Duration(i) * time.Minute).Format(fileTimeLayout)
+		os.WriteFile(filepath.Join(dir, name+".log"), []byte("old\n"), 0o644)
+	}
+
+	// No line was written, yet new limits apply to the files already there
+	store := NewRollingStore(dir, 0, 2)
+	store.SetRotation(Rotation{Compress: true})
+	waitSegments(t, dir, func(segments []segment) bool {
+		return len(segments) == 2 && segments[0].compressed && segments[1].compressed
+	})
+}
+
+func TestIntervalStart(t *testing.T) {
+	at := time.Date(2026, 3, 4, 15, 42, 7, 0, time.Local)
+	if got := intervalStart(RotateHourly, at); !got.Equal(time.Date(2026, 3, 4, 15, 0, 0, 0, time.Local)) {
+		t.Errorf("hourly start = %v", got)
+	}
+	if got := intervalStart(RotateDaily, at); !got.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("daily start = %v", got)
+	}
+}
+
+func TestRollingStoreBuffersUntilFlush(t *testing.T) {
+	dir := t.TempDir()
+	store := NewRollingStore(dir, 1000, 5)
+	defer store.Close()
+	if err := store.Append(Entry{Timestamp: time.Now(), Stream: "stdout", Line: "hello"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	read := func() string {
+		segments, _ := listSegments(dir)
+		if len(segments) != 1 {
+			t.Fatalf("expected one log file, got %+v", segments)
+		}
+		data, _ := readSegment(dir, segments[0].name)
+		return string(data)
+	}
+	if got := read(); got != "" {
+		t.Fatalf("expected the line to wait in the buffer, file has %q", got)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if got := read(); !strings.HasSuffix(got, " stdout hello\n") {
+		t.Fatalf("expected the flushed line, file has %q", got)
+	}
+}
+
+func TestRollingStoreDropsLinesAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	store := NewRollingStore(dir, 1000, 5)
+	if err := store.Append(Entry{Timestamp: time.Now(), Stream: "stdout", Line: "before"}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := store.Append(Entry{Timestamp: time.Now(), Stream: "stdout", Line: "after"}); err != nil {
+		t.Fatalf("Append after Close failed: %v", err)
+	}
+	_ = store.Flush()
+
+	segments, _ := listSegments(dir)
+	if len(segments) != 1 {
+		t.Fatalf("expected one log file, got %+v", segments)
+	}
+	data, _ := readSegment(dir, segments[0].name)
+	if got := string(data); !strings.HasSuffix(got, " stdout before\n") {
+		t.Fatalf("expected only the line from before Close, file has %q", got)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.file != nil || store.maintainer != nil {
+		t.Fatal("Append after Close reopened the store")
+	}
+}
+
+func benchmarkAppend(b *testing.B, sync SyncPolicy) {
+	store := NewRollingStore(b.TempDir(), 100000, 5)
+	store.SetSync(sync)
+	defer store.Close()
+	entry := Entry{Timestamp: time.Now(), Stream: "stdout", Line: strings.Repeat("x", 100)}
+	b.SetBytes(int64(len(entry.Line)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.Append(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRollingStoreAppend(b *testing.B)             { benchmarkAppend(b, SyncNever) }
+func BenchmarkRollingStoreAppendSyncInterval(b *testing.B) { benchmarkAppend(b, SyncInterval) }
+func BenchmarkRollingStoreAppendSyncAlways(b *testing.B)   { benchmarkAppend(b, SyncAlways) }
@@ -0,0 +1,315 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultSearchLimit     = 200
+	DefaultMaxScannedBytes = 64 << 20
+)
+
+var ErrInvalidCursor = errors.New("invalid search cursor")
+
+// Query selects log entries for Search. Zero values match everything.
+type Query struct {
+	Since         *time.Time        `json:"since,omitempty"`
+	Until         *time.Time        `json:"until,omitempty"`
+	Stream        string            `json:"stream,omitempty"` // stdout, stderr or empty for both
+	Text          string            `json:"text,omitempty"`
+	Fields        map[string]string `json:"fields,omitempty"`   // Values fields of JSON log lines must have, see Entry.Field
+	MinLevel      string            `json:"minLevel,omitempty"` // Least severe level matched, e.g. warn
+	Regex         bool              `json:"regex"`              // Text is a regular expression
+	CaseSensitive bool              `json:"caseSensitive"`      // Applies to substring and regex searches
+	Limit         int               `json:"limit,omitempty"`
+	Cursor        string            `json:"cursor,omitempty"`       // NextCursor of the previous page
+	MaxScanBytes  int64             `json:"maxScanBytes,omitempty"` // Bytes read before giving up on a page
+}
+
+// Source is a log directory to search, with the classifier that assigns
+// levels to its entries
+type Source struct {
+	Dir    string
+	Levels *Classifier
+}
+
+// Match is an entry found by Search, tagged with the source it came from
+type Match struct {
+	Entry
+	Source string `json:"source"`
+}
+
+// Result is a page of matches, newest first.
+type Result struct {
+	Matches      []Match `json:"matches"`
+	NextCursor   string  `json:"nextCursor,omitempty"` // Empty once everything has been searched
+	ScannedBytes int64   `json:"scannedBytes"`
+	// Truncated is set when the scan limit ended the page before Limit
+	// matches were found; NextCursor continues from there
+	Truncated bool `json:"truncated"`
+}
+
+// position is where a source resumes, scanning backwards: the bytes before
+// Offset in File are still to be read. Done sources have nothing left.
+type position struct {
+	File   string `json:"f,omitempty"`
+	Offset int64  `json:"o"`
+	Done   bool   `json:"d,omitempty"`
+}
+
+// Search looks through the rotated log files of the sources, keyed by name,
+// and returns matching entries newest first, merging the sources by time.
+func Search(sources map[string]Source, q Query) (Result, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.MaxScanBytes <= 0 {
+		q.MaxScanBytes = DefaultMaxScannedBytes
+	}
+	match, err := Matcher(q)
+	if err != nil {
+		return Result{}, err
+	}
+	positions := make(map[string]position)
+	if q.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil || json.Unmarshal(data, &positions) != nil {
+			return Result{}, ErrInvalidCursor
+		}
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	scanners := make([]*scanner, 0, len(names))
+	for _, name := range names {
+		s, err := newScanner(name, sources[name], positions[name], q.Until)
+		if err != nil {
+			return Result{}, err
+		}
+		defer s.close()
+		scanners = append(scanners, s)
+	}
+
+	result := Result{Matches: make([]Match, 0)}
+	for len(result.Matches) < q.Limit {
+		if result.ScannedBytes >= q.MaxScanBytes {
+			result.Truncated = true
+			break
+		}
+		// Take the newest entry among the sources
+		var next *scanner
+		for _, s := range scanners {
+			if err := s.fill(&result.ScannedBytes); err != nil {
+				return Result{}, err
+			}
+			if s.ok && (next == nil || s.head.Timestamp.After(next.head.Timestamp)) {
+				next = s
+			}
+		}
+		if next == nil {
+			break
+		}
+		entry := next.head
+		next.ok = false
+		if q.Since != nil && entry.Timestamp.Before(*q.Since) {
+			// Files are in time order, so nothing older can match
+			next.done = true
+			continue
+		}
+		if q.Until != nil && entry.Timestamp.After(*q.Until) {
+			continue
+		}
+		if match(entry) {
+			result.Matches = append(result.Matches, Match{Entry: entry, Source: next.name})
+		}
+	}
+
+	more := false
+	next := make(map[string]position, len(scanners))
+	for _, s := range scanners {
+		pos := s.position()
+		next[s.name] = pos
+		more = more || !pos.Done
+	}
+	result.Truncated = result.Truncated && more
+	if more {
+		data, _ := json.Marshal(next)
+		result.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return result, nil
+}
+
+// Matcher builds the stream, level, field and text filter of q. The time
+// range, limit and cursor are left to the caller.
+func Matcher(q Query) (func(Entry) bool, error) {
+	if q.MinLevel != "" && NormalizeLevel(q.MinLevel) == "" {
+		return nil, ErrUnknownLevel
+	}
+	text := func(string) bool { return true }
+	switch {
+	case q.Text != "" && q.Regex:
+		expr := q.Text
+		if !q.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		text = re.MatchString
+	case q.Text != "" && q.CaseSensitive:
+		text = func(line string) bool { return strings.Contains(line, q.Text) }
+	case q.Text != "":
+		needle := strings.ToLower(q.Text)
+		text = func(line string) bool { return strings.Contains(strings.ToLower(line), needle) }
+	}
+	return func(e Entry) bool {
+		if q.Stream != "" && e.Stream != q.Stream {
+			return false
+		}
+		if !LevelAtLeast(e.Level, q.MinLevel) {
+			return false
+		}
+		for name, want := range q.Fields {
+			if value, ok := e.Field(name); !ok || value != want {
+				return false
+			}
+		}
+		return text(e.Line)
+	}, nil
+}
+
+// scanner reads the entries of one source backwards, a file at a time
+type scanner struct {
+	name   string
+	dir    string
+	levels *Classifier
+	files  []string       // still to be read, oldest first
+	resume int64          // offset of the last of files to read from
+	file   string         // file being read
+	reader *reverseReader // reads file, nil once it is done
+	head   Entry          // next entry, valid when ok
+	// headOffset is where head ends in file
+	headOffset int64
+	ok         bool
+	done       bool
+}
+
+func newScanner(name string, source Source, pos position, until *time.Time) (*scanner, error) {
+	s := &scanner{name: name, dir: source.Dir, levels: source.Levels, resume: math.MaxInt64, done: pos.Done}
+	if s.done {
+		return s, nil
+	}
+	segments, err := listSegments(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(segments))
+	for i, seg := range segments {
+		files[i] = seg.name
+	}
+	if pos.File != "" {
+		// Files rotated away since the last page are simply skipped
+		keep := sort.SearchStrings(files, pos.File)
+		if keep < len(files) && files[keep] == pos.File {
+			s.resume = pos.Offset
+			keep++
+		}
+		files = files[:keep]
+	}
+	if until != nil {
+		// A file only holds entries written after it was created
+		for len(files) > 0 && segmentStart(files[len(files)-1]).After(*until) {
+			files = files[:len(files)-1]
+			s.resume = math.MaxInt64
+		}
+	}
+	s.files = files
+	return s, nil
+}
+
+// fill loads the next entry into head, opening older files as needed and
+// counting the bytes read
+func (s *scanner) fill(scanned *int64) error {
+	for !s.ok && !s.done {
+		if s.reader == nil {
+			if len(s.files) == 0 {
+				s.done = true
+				return nil
+			}
+			s.file = s.files[len(s.files)-1]
+			s.files = s.files[:len(s.files)-1]
+			reader, err := openReverse(s.dir, s.file, s.resume)
+			s.resume = math.MaxInt64
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return err
+			}
+			s.reader = reader
+			continue
+		}
+		end := s.reader.offset
+		line, ok, err := s.reader.next()
+		// Reading backwards, continuation lines come before their entry
+		for ok && err == nil && strings.HasPrefix(line, continuationIndent) {
+			before, more, readErr := s.reader.next()
+			if err = readErr; !more {
+				break
+			}
+			line = before + "\n" + line
+		}
+		*scanned += end - s.reader.offset
+		if err != nil {
+			return err
+		}
+		if !ok {
+			s.reader.close()
+			s.reader = nil
+			continue
+		}
+		if entry, ok := ParseLine(line); ok {
+			// Text files do not keep the level
+			s.levels.Classify(&entry)
+			s.head, s.ok, s.headOffset = entry, true, end
+		}
+	}
+	return nil
+}
+
+// position returns where the next page resumes, putting back an entry
+// that was read but not used
+func (s *scanner) position() position {
+	if s.done {
+		return position{Done: true}
+	}
+	if s.ok {
+		return position{File: s.file, Offset: s.headOffset}
+	}
+	if s.reader != nil && s.reader.offset > 0 {
+		return position{File: s.file, Offset: s.reader.offset}
+	}
+	if len(s.files) == 0 {
+		return position{Done: true}
+	}
+	// Resume at the end of the next older file, or where a cursor left it
+	return position{File: s.files[len(s.files)-1], Offset: s.resume}
+}
+
+func (s *scanner) close() {
+	if s.reader != nil {
+		s.reader.close()
+	}
+}
@@ -0,0 +1,269 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLog writes entries to a log file of dir in the RollingStore format
+func writeLog(t *testing.T, dir, name string, entries ...Entry) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.Timestamp.Format(time.RFC3339) + " " + e.Stream + " " + e.Line + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchMergesAndPages(t *testing.T) {
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	at := func(sec int, stream, line string) Entry {
+		return Entry{Timestamp: base.Add(time.Duration(sec) * time.Second), Stream: stream, Line: line}
+	}
+	api, worker := filepath.Join(t.TempDir(), "api"), filepath.Join(t.TempDir(), "worker")
+	writeLog(t, api, "20260102_100000.log", at(0, "stdout", "api started"), at(2, "stderr", "api error 1"))
+	writeLog(t, api, "20260102_100003.log", at(4, "stdout", "api request"), at(6, "stderr", "API ERROR 2"))
+	writeLog(t, worker, "20260102_100000.log", at(1, "stdout", "worker started"), at(5, "stderr", "worker error"))
+	sources := map[string]Source{"api": {Dir: api}, "worker": {Dir: worker}}
+
+	var got []string
+	cursor := ""
+	for page := 0; ; page++ {
+		result, err := Search(sources, Query{Text: "error", Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, m := range result.Matches {
+			got = append(got, m.Source+": "+m.Line)
+		}
+		if cursor = result.NextCursor; cursor == "" {
+			break
+		}
+		if page > 5 {
+			t.Fatal("search did not finish")
+		}
+	}
+	want := []string{"api: API ERROR 2", "worker: worker error", "api: api error 1"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("matches = %q, want %q", got, want)
+	}
+
+	since := base.Add(2 * time.Second)
+	result, err := Search(sources, Query{Text: `^api (started|request)$`, Regex: true, Stream: "stdout", Since: &since})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Line != "api request" || result.NextCursor != "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	result, err = Search(sources, Query{MaxScanBytes: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if !result.Truncated || result.NextCursor == "" {
+		t.Fatalf("expected a truncated page with a cursor, got %+v", result)
+	}
+}
+
+func TestStructuredEntries(t *testing.T) {
+	dir := t.TempDir()
+	store := NewRollingStore(dir, 1000, 5)
+	base := time.Date(2026, 1, 2, 10, 0, 0, 123456789, time.UTC)
+	lines := []string{
+		`plain text`,
+		`{"level":"ERROR","msg":"db down","service":{"name":"api"},"attempt":3}`,
+		`{"level":50,"msg":"pino error","service":{"name":"worker"}}`,
+	}
+	for i, line := range lines {
+		if i == 2 {
+			// Files stay readable when the format changes
+			store.SetFormat(FormatNDJSON)
+		}
+		e := Entry{Seq: uint64(i + 1), Timestamp: base.Add(time.Duration(i)), Stream: "stdout", Line: line}
+		e.ParseJSON()
+		if err := store.Append(e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	store.Close()
+
+	result, err := Search(map[string]Source{"proc": {Dir: dir}}, Query{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Matches) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", result.Matches)
+	}
+	pino, db := result.Matches[0], result.Matches[1]
+	if pino.Seq != 3 || pino.Level != "error" || pino.Message != "pino error" || !pino.Timestamp.Equal(base.Add(2)) {
+		t.Errorf("unexpected NDJSON entry %+v", pino)
+	}
+	if db.Level != "error" || db.Message != "db down" || !db.Timestamp.Equal(base.Add(1)) {
+		t.Errorf("unexpected text entry %+v", db)
+	}
+	if value, _ := db.Field("attempt"); value != "3" {
+		t.Errorf("attempt = %q, want 3", value)
+	}
+
+	result, err = Search(map[string]Source{"proc": {Dir: dir}}, Query{Fields: map[string]string{"service.name": "api", "level": "error"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Message != "db down" {
+		t.Fatalf("expected the api error, got %+v", result.Matches)
+	}
+	result, err = Search(map[string]Source{"proc": {Dir: dir}}, Query{MinLevel: "warn"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Matches) != 2 {
+		t.Fatalf("expected the two errors, got %+v", result.Matches)
+	}
+	if _, err := Search(map[string]Source{"proc": {Dir: dir}}, Query{MinLevel: "loud"}); err != ErrUnknownLevel {
+		t.Errorf("expected ErrUnknownLevel, got %v", err)
+	}
+}
+
+func TestMultilineEntries(t *testing.T) {
+	dir := t.TempDir()
+	store := NewRollingStore(dir, 1000, 5)
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	trace := "Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\nZeroDivisionError: division by zero"
+	for i, line := range []string{"before", trace, "\tafter, starting with a tab"} {
+		if err := store.Append(Entry{Timestamp: base.Add(time.Duration(i) * time.Second), Stream: "stderr", Line: line}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	store.Close()
+
+	// One entry per page, so the cursor has to step over the whole trace
+	var lines []string
+	cursor := ""
+	for {
+		result, err := Search(map[string]Source{"proc": {Dir: dir}}, Query{Limit: 1, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, m := range result.Matches {
+			lines = append(lines, m.Line)
+		}
+		if cursor = result.NextCursor; cursor == "" {
+			break
+		}
+	}
+	want := []string{"\tafter, starting with a tab", trace, "before"}
+	if fmt.Sprintf("%q", lines) != fmt.Sprintf("%q", want) {
+		t.Fatalf("entries = %q, want %q", lines, want)
+	}
+}
+
+func TestSearchScanLimitResumes(t *testing.T) {
+	dir := t.TempDir()
+	// Several chunks of lines, with multi-line entries across chunk ends
+	store := NewRollingStore(dir, 0, 0)
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	var want []string
+	for i := 0; i < 3000; i++ {
+		line := fmt.Sprintf("line %d %s", i, strings.Repeat("x", 60))
+		if i%7 == 0 {
+			line += "\n\tat frame one\n\tat frame two"
+		}
+		if err := store.Append(Entry{Timestamp: base.Add(time.Duration(i) * time.Millisecond), Stream: "stdout", Line: line}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		want = append([]string{line}, want...)
+	}
+	store.Close()
+	segments, _ := listSegments(dir)
+	size := segments[0].size
+
+	search := func() {
+		t.Helper()
+		var got []string
+		cursor := ""
+		for pages := 0; ; pages++ {
+			result, err := Search(map[string]Source{"proc": {Dir: dir}}, Query{Limit: 5000, MaxScanBytes: 16 << 10, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			// A page stops within an entry of the limit instead of reading the file
+			if result.ScannedBytes > 17<<10 {
+				t.Fatalf("page scanned %d bytes", result.ScannedBytes)
+			}
+			for _, m := range result.Matches {
+				got = append(got, m.Line)
+			}
+			if cursor = result.NextCursor; cursor == "" {
+				break
+			}
+			if pages > int(size/(16<<10))+2 {
+				t.Fatal("search did not finish")
+			}
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+			t.Fatalf("got %d entries, want %d", len(got), len(want))
+		}
+	}
+	search()
+	// Offsets in the cursor refer to the uncompressed file
+	if err := compressFile(filepath.Join(dir, segments[0].file)); err != nil {
+		t.Fatal(err)
+	}
+	search()
+}
+
+func TestMerge(t *testing.T) {
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	at := func(sec int, line string) Entry {
+		return Entry{Timestamp: base.Add(time.Duration(sec) * time.Second), Stream: "stdout", Line: line}
+	}
+	apiDir, workerDir := t.TempDir(), t.TempDir()
+	// The newest api entries are both in memory and on disk
+	writeLog(t, apiDir, "20260102_100000.log", at(0, "api 0"), at(2, "ERROR api 2"), at(4, "api 4"), at(6, "WARN api 6"))
+	hub := NewStreamHub(2)
+	// Entries are classified before they reach the hub
+	warn := at(6, "WARN api 6")
+	warn.Level = LevelWarn
+	hub.Push(at(4, "api 4"))
+	hub.Push(warn)
+	hub.Push(at(7, "api 7 not flushed yet"))
+	writeLog(t, workerDir, "20260102_100000.log", at(1, "worker 1"), at(3, "ERROR worker 3"), at(5, "worker 5"))
+
+	feeds := []Feed{
+		{ID: "api", Name: "API", Hub: hub, Source: Source{Dir: apiDir}},
+		{ID: "worker", Name: "Worker", Source: Source{Dir: workerDir}},
+	}
+	entries, err := Merge(feeds, Query{})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.ProcessName+": "+e.Line)
+	}
+	want := []string{
+		"API: api 0", "Worker: worker 1", "API: ERROR api 2", "Worker: ERROR worker 3",
+		"API: api 4", "Worker: worker 5", "API: WARN api 6", "API: api 7 not flushed yet",
+	}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Fatalf("merged = %q, want %q", got, want)
+	}
+
+	entries, err = Merge(feeds, Query{MinLevel: LevelWarn, Limit: 2})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ProcessID != "worker" || entries[1].Line != "WARN api 6" {
+		t.Fatalf("expected the two newest warnings and errors, got %+v", entries)
+	}
+}
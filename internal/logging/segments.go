@@ -0,0 +1,276 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const gzipSuffix = ".gz"
+
+// segment is a log file of a RollingStore. Compressed segments keep the
+// name of the file they were made from, so cursors survive compression.
+type segment struct {
+	name       string // e.g. 20060102_150405.log
+	file       string // name on disk, with .gz when compressed
+	compressed bool
+	size       int64
+	modTime    time.Time
+}
+
+// listSegments lists the log files of dir, oldest first. A file caught
+// halfway through compression is listed once, uncompressed.
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	byName := make(map[string]segment, len(entries))
+	for _, entry := range entries {
+		file := entry.Name()
+		name := strings.TrimSuffix(file, gzipSuffix)
+		if entry.IsDir() || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		compressed := name != file
+		if existing, ok := byName[name]; ok && !existing.compressed {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		byName[name] = segment{name: name, file: file, compressed: compressed, size: info.Size(), modTime: info.ModTime()}
+	}
+	segments := make([]segment, 0, len(byName))
+	for _, seg := range byName {
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].name < segments[j].name })
+	return segments, nil
+}
+
+// segmentStart returns the creation time encoded in a segment name
+func segmentStart(name string) time.Time {
+	if len(name) < len(fileTimeLayout) {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation(fileTimeLayout, name[:len(fileTimeLayout)], time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// readSegment reads the segment called name in dir, whether or not it has
+// been compressed since it was listed
+func readSegment(dir, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return ReadLogFile(filepath.Join(dir, name+gzipSuffix))
+	}
+	return data, err
+}
+
+// firstEntry parses the first line of the segment called name in dir
+func firstEntry(dir, name string) (Entry, bool) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(dir, name+gzipSuffix))
+		if err == nil {
+			defer f.Close()
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				return Entry{}, false
+			}
+			defer zr.Close()
+			return parseFirstLine(zr)
+		}
+	}
+	if err != nil {
+		return Entry{}, false
+	}
+	defer f.Close()
+	return parseFirstLine(f)
+}
+
+func parseFirstLine(r io.Reader) (Entry, bool) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return Entry{}, false
+	}
+	return ParseLine(strings.TrimSuffix(line, "\n"))
+}
+
+// ReadLogFile reads a log file, decompressing it if it is gzipped
+func ReadLogFile(path string) ([]byte, error) {
+	if !strings.HasSuffix(path, gzipSuffix) {
+		return os.ReadFile(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// compressFile gzips path into path.gz, keeping its modification time, and
+// removes it. The original stays readable until the compressed copy is
+// complete.
+func compressFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + gzipSuffix + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = info.ModTime()
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+gzipSuffix)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	_ = os.Chtimes(path+gzipSuffix, info.ModTime(), info.ModTime())
+	src.Close()
+	return os.Remove(path)
+}
+
+// reverseChunkSize is how much of a log file is read at a time when
+// reading it backwards
+const reverseChunkSize = 64 << 10
+
+// reverseReader reads the lines of a segment backwards, from its end or
+// from an offset, a chunk at a time
+type reverseReader struct {
+	r      io.ReaderAt
+	file   *os.File // nil for a compressed segment, which is held in memory
+	off    int64    // bytes before off are not loaded yet
+	carry  []byte   // start of a line whose beginning is before off
+	lines  []string // loaded lines, in file order
+	offset int64    // bytes before offset are still to be read
+}
+
+// openReverse opens the segment called name in dir for reading backwards
+// from offset, or from its end when offset is past it. A compressed segment
+// cannot be read backwards, so it is decompressed up to offset.
+func openReverse(dir, name string, offset int64) (*reverseReader, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		if f, err = os.Open(filepath.Join(dir, name+gzipSuffix)); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		data, err := io.ReadAll(io.LimitReader(zr, offset))
+		if err != nil {
+			return nil, err
+		}
+		size := int64(len(data))
+		return &reverseReader{r: bytes.NewReader(data), off: size, offset: size}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	offset = min(offset, info.Size())
+	return &reverseReader{r: f, file: f, off: offset, offset: offset}, nil
+}
+
+// next returns the line before the ones already read, and false once the
+// start of the file is reached
+func (b *reverseReader) next() (string, bool, error) {
+	for {
+		if n := len(b.lines); n > 0 {
+			line := b.lines[n-1]
+			b.lines = b.lines[:n-1]
+			b.offset = max(b.offset-int64(len(line))-1, 0)
+			// Only the newline ending the file yields an empty line
+			if line == "" {
+				continue
+			}
+			return line, true, nil
+		}
+		if b.off == 0 {
+			if len(b.carry) == 0 {
+				return "", false, nil
+			}
+			line := string(b.carry)
+			b.carry = nil
+			b.offset = 0
+			return line, true, nil
+		}
+		size := min(reverseChunkSize, b.off)
+		chunk := make([]byte, size, size+int64(len(b.carry)))
+		if _, err := b.r.ReadAt(chunk, b.off-size); err != nil && err != io.EOF {
+			return "", false, err
+		}
+		b.off -= size
+		data := append(chunk, b.carry...)
+		// The first line may begin in the chunk before
+		first := bytes.IndexByte(data, '\n')
+		if first < 0 {
+			b.carry = data
+			continue
+		}
+		b.carry = data[:first]
+		b.lines = strings.Split(string(data[first+1:]), "\n")
+	}
+}
+
+func (b *reverseReader) close() {
+	if b.file != nil {
+		b.file.Close()
+	}
+}
+
+// readLines reads a whole log file
+func readLines(dir, name string) ([]string, error) {
+	data, err := readSegment(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
@@ -33,3 +33,15 @@ func (h *StreamHub) Snapshot() []Entry {
 	copy(data, h.entries)
 	return data
 }
+
+// SetLimit changes how many entries the hub keeps, dropping the oldest
+// ones beyond it
+func (h *StreamHub) SetLimit(limit int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.limit = limit
+	if len(h.entries) > limit {
+		h.entries = append([]Entry(nil), h.entries[len(h.entries)-limit:]...)
+	}
+}
@@ -0,0 +1,387 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"prochub/internal/process"
+
+	"github.com/google/uuid"
+)
+
+// maxRunHistory is the number of finished runs kept per pipeline
+const maxRunHistory = 20
+
+var (
+	ErrRunNotFound    = errors.New("pipeline run not found")
+	ErrAlreadyRunning = errors.New("pipeline is already running")
+)
+
+// Runner executes pipelines on top of a process.Manager
+type Runner struct {
+	mu   sync.Mutex
+	pm   *process.Manager
+	runs map[string][]*runState // by pipeline ID, oldest first
+}
+
+type runState struct {
+	run    Run
+	cancel context.CancelFunc
+}
+
+// stepResult is sent by a step goroutine when the step finishes
+type stepResult struct {
+	stepID string
+	status StepStatus
+}
+
+func NewRunner(pm *process.Manager) *Runner {
+	return &Runner{
+		pm:   pm,
+		runs: make(map[string][]*runState),
+	}
+}
+
+// Validate checks that step IDs are unique, every transition points to an
+// existing step and the steps do not form a cycle.
+func Validate(def Definition) error {
+	if len(def.Steps) == 0 {
+		return errors.New("pipeline has no steps")
+	}
+	steps := make(map[string]Step, len(def.Steps))
+	for _, step := range def.Steps {
+		if step.ID == "" {
+			return errors.New("step id is required")
+		}
+		if step.ProcessID == "" {
+			return fmt.Errorf("step %s has no process", step.ID)
+		}
+		if _, dup := steps[step.ID]; dup {
+			return fmt.Errorf("duplicate step id %s", step.ID)
+		}
+		steps[step.ID] = step
+	}
+	for _, step := range def.Steps {
+		for _, next := range successors(step) {
+			if _, ok := steps[next]; !ok {
+				return fmt.Errorf("step %s points to unknown step %s", step.ID, next)
+			}
+		}
+	}
+
+	// Depth-first search for a back edge
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(steps))
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch marks[id] {
+		case visiting:
+			return fmt.Errorf("step %s is part of a cycle", id)
+		case visited:
+			return nil
+		}
+		marks[id] = visiting
+		for _, next := range successors(steps[id]) {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		marks[id] = visited
+		return nil
+	}
+	for _, step := range def.Steps {
+		if err := visit(step.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start begins a run of def and returns it without waiting for it to finish.
+// Only one run of a pipeline may be active at a time.
+func (r *Runner) Start(ctx context.Context, def Definition) (Run, error) {
+	if err := Validate(def); err != nil {
+		return Run{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, state := range r.runs[def.ID] {
+		if state.run.Status == RunRunning {
+			return Run{}, ErrAlreadyRunning
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	state := &runState{
+		run: Run{
+			ID:         uuid.New().String(),
+			PipelineID: def.ID,
+			Status:     RunRunning,
+			Steps:      make([]StepRun, 0, len(def.Steps)),
+			StartedAt:  time.Now(),
+		},
+		cancel: cancel,
+	}
+	for _, step := range def.Steps {
+		state.run.Steps = append(state.run.Steps, StepRun{
+			StepID:    step.ID,
+			ProcessID: step.ProcessID,
+			Status:    StepPending,
+		})
+	}
+
+	history := append(r.runs[def.ID], state)
+	if len(history) > maxRunHistory {
+		history = history[len(history)-maxRunHistory:]
+	}
+	r.runs[def.ID] = history
+
+	go r.execute(runCtx, def, state)
+	return copyRun(state.run), nil
+}
+
+// Runs returns the runs of a pipeline, newest first
+func (r *Runner) Runs(pipelineID string) []Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.runs[pipelineID]
+	runs := make([]Run, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		runs = append(runs, copyRun(history[i].run))
+	}
+	return runs
+}
+
+// Get returns a single run by ID
+func (r *Runner) Get(runID string) (Run, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.find(runID)
+	if state == nil {
+		return Run{}, ErrRunNotFound
+	}
+	return copyRun(state.run), nil
+}
+
+// Cancel stops a running pipeline. Steps that are running are stopped and
+// steps that have not started are skipped.
+func (r *Runner) Cancel(runID string) error {
+	r.mu.Lock()
+	state := r.find(runID)
+	r.mu.Unlock()
+
+	if state == nil {
+		return ErrRunNotFound
+	}
+	state.cancel()
+	return nil
+}
+
+// Forget drops the run history of a pipeline, cancelling an active run
+func (r *Runner) Forget(pipelineID string) {
+	r.mu.Lock()
+	history := r.runs[pipelineID]
+	delete(r.runs, pipelineID)
+	r.mu.Unlock()
+
+	for _, state := range history {
+		state.cancel()
+	}
+}
+
+func (r *Runner) find(runID string) *runState {
+	for _, history := range r.runs {
+		for _, state := range history {
+			if state.run.ID == runID {
+				return state
+			}
+		}
+	}
+	return nil
+}
+
+// execute drives a run. A step starts once every step that points to it has
+// finished and at least one of them took the transition to it; if none did,
+// the step is skipped. Steps nothing points to start right away.
+func (r *Runner) execute(ctx context.Context, def Definition, state *runState) {
+	defer state.cancel()
+
+	steps := make(map[string]Step, len(def.Steps))
+	remaining := make(map[string]int, len(def.Steps))
+	triggered := make(map[string]bool, len(def.Steps))
+	for _, step := range def.Steps {
+		steps[step.ID] = step
+		for _, next := range successors(step) {
+			remaining[next]++
+		}
+	}
+
+	results := make(chan stepResult)
+	running := 0
+	failed := false
+
+	var skip func(id string)
+	launch := func(id string) {
+		if ctx.Err() != nil {
+			skip(id)
+			return
+		}
+		running++
+		r.setStep(state, id, func(sr *StepRun) {
+			now := time.Now()
+			sr.Status = StepRunning
+			sr.StartedAt = &now
+		})
+		go func(step Step) {
+			results <- stepResult{stepID: step.ID, status: r.runStep(ctx, state, step)}
+		}(steps[id])
+	}
+	// skip marks a step that will not run and releases its successors
+	skip = func(id string) {
+		r.setStep(state, id, func(sr *StepRun) { sr.Status = StepSkipped })
+		for _, next := range successors(steps[id]) {
+			r.release(next, remaining, triggered, launch, skip)
+		}
+	}
+
+	for _, step := range def.Steps {
+		if remaining[step.ID] == 0 {
+			launch(step.ID)
+		}
+	}
+
+	for running > 0 {
+		result := <-results
+		running--
+
+		step := steps[result.stepID]
+		taken := step.OnSuccess
+		if result.status == StepFailed {
+			taken = step.OnFailure
+			if len(step.OnFailure) == 0 {
+				failed = true
+			}
+		}
+		for _, next := range taken {
+			triggered[next] = true
+		}
+		for _, next := range successors(step) {
+			r.release(next, remaining, triggered, launch, skip)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	state.run.FinishedAt = &now
+	switch {
+	case ctx.Err() != nil:
+		state.run.Status = RunCancelled
+	case failed:
+		state.run.Status = RunFailed
+	default:
+		state.run.Status = RunSucceeded
+	}
+}
+
+// release records that one predecessor of id has finished and starts or
+// skips id once all of them have
+func (r *Runner) release(id string, remaining map[string]int, triggered map[string]bool, launch, skip func(string)) {
+	remaining[id]--
+	if remaining[id] > 0 {
+		return
+	}
+	if triggered[id] {
+		launch(id)
+	} else {
+		skip(id)
+	}
+}
+
+// runStep runs the step's process to completion and reports the outcome
+func (r *Runner) runStep(ctx context.Context, state *runState, step Step) StepStatus {
+	status := StepFailed
+	errText := ""
+	var exitCode *int
+
+	if err := r.pm.RunNow(ctx, step.ProcessID); err != nil {
+		errText = err.Error()
+	} else {
+		snap, err := r.pm.Wait(ctx, step.ProcessID)
+		if err != nil {
+			// Cancelled while the step was running
+			_ = r.pm.Stop(step.ProcessID)
+			snap, _ = r.pm.Get(step.ProcessID)
+			errText = err.Error()
+		} else {
+			errText = snap.LastError
+		}
+		exitCode = snap.ExitCode
+		if err == nil && isSuccess(snap) {
+			status = StepSucceeded
+		}
+	}
+
+	r.setStep(state, step.ID, func(sr *StepRun) {
+		now := time.Now()
+		sr.Status = status
+		sr.Error = errText
+		sr.ExitCode = exitCode
+		sr.FinishedAt = &now
+	})
+	return status
+}
+
+func (r *Runner) setStep(state *runState, stepID string, update func(*StepRun)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range state.run.Steps {
+		if state.run.Steps[i].StepID == stepID {
+			update(&state.run.Steps[i])
+			return
+		}
+	}
+}
+
+// isSuccess reports whether a finished run of a process counts as success.
+// Tasks end as completed; a service counts when it exited cleanly.
+func isSuccess(snap process.Snapshot) bool {
+	switch snap.Status {
+	case process.StatusCompleted:
+		return true
+	case process.StatusStopped:
+		return snap.LastError == ""
+	}
+	return false
+}
+
+// successors returns the distinct steps a step may transition to
+func successors(step Step) []string {
+	seen := make(map[string]bool, len(step.OnSuccess)+len(step.OnFailure))
+	next := make([]string, 0, len(step.OnSuccess)+len(step.OnFailure))
+	for _, list := range [][]string{step.OnSuccess, step.OnFailure} {
+		for _, id := range list {
+			if !seen[id] {
+				seen[id] = true
+				next = append(next, id)
+			}
+		}
+	}
+	return next
+}
+
+func copyRun(run Run) Run {
+	run.Steps = append([]StepRun(nil), run.Steps...)
+	return run
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"prochub/internal/process"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Definition{ID: "p", Steps: []Step{
+		{ID: "build", ProcessID: "proc-1", OnSuccess: []string{"test", "lint"}},
+		{ID: "test", ProcessID: "proc-2", OnSuccess: []string{"deploy"}},
+		{ID: "lint", ProcessID: "proc-3", OnSuccess: []string{"deploy"}},
+		{ID: "deploy", ProcessID: "proc-4"},
+	}}
+	if err := Validate(valid); err != nil {
+		t.Errorf("Validate(valid) = %v", err)
+	}
+
+	cases := map[string]Definition{
+		"empty":     {ID: "p"},
+		"duplicate": {ID: "p", Steps: []Step{{ID: "a", ProcessID: "x"}, {ID: "a", ProcessID: "y"}}},
+		"unknown":   {ID: "p", Steps: []Step{{ID: "a", ProcessID: "x", OnSuccess: []string{"b"}}}},
+		"cycle": {ID: "p", Steps: []Step{
+			{ID: "a", ProcessID: "x", OnSuccess: []string{"b"}},
+			{ID: "b", ProcessID: "y", OnFailure: []string{"a"}},
+		}},
+	}
+	for name, def := range cases {
+		if err := Validate(def); err == nil {
+			t.Errorf("Validate(%s) succeeded, want error", name)
+		}
+	}
+}
+
+// waitRun polls until the run is no longer running or fails the test.
+func waitRun(t *testing.T, r *Runner, runID string) Run {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		run, err := r.Get(runID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if run.Status != RunRunning {
+			return run
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run did not finish: %+v", run)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunnerTransitions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses true/false commands")
+	}
+
+	pm := process.NewManager()
+	for id, command := range map[string]string{"build": "true", "test": "false", "deploy": "true", "notify": "true"} {
+		pm.Register(process.Definition{ID: id, Kind: process.KindTask, Command: command, RestartPolicy: process.RestartNever})
+	}
+	r := NewRunner(pm)
+
+	def := Definition{ID: "ci", Steps: []Step{
+		{ID: "build", ProcessID: "build", OnSuccess: []string{"test"}},
+		{ID: "test", ProcessID: "test", OnSuccess: []string{"deploy"}, OnFailure: []string{"notify"}},
+		{ID: "deploy", ProcessID: "deploy"},
+		{ID: "notify", ProcessID: "notify"},
+	}}
+	run, err := r.Start(context.Background(), def)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := r.Start(context.Background(), def); err != ErrAlreadyRunning {
+		t.Errorf("expected ErrAlreadyRunning for a second run, got %v", err)
+	}
+
+	run = waitRun(t, r, run.ID)
+	if run.Status != RunSucceeded {
+		t.Errorf("run status = %q, want %q since the failure was handled", run.Status, RunSucceeded)
+	}
+	want := map[string]StepStatus{"build": StepSucceeded, "test": StepFailed, "deploy": StepSkipped, "notify": StepSucceeded}
+	for _, step := range run.Steps {
+		if step.Status != want[step.StepID] {
+			t.Errorf("step %s status = %q, want %q", step.StepID, step.Status, want[step.StepID])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import "time"
+
+// Step runs one managed process as part of a pipeline.
+type Step struct {
+	ID        string   `json:"id"`
+	ProcessID string   `json:"processId"`
+	OnSuccess []string `json:"onSuccess"` // Steps to run when this step succeeds; several run in parallel
+	OnFailure []string `json:"onFailure"` // Steps to run when this step fails
+}
+
+// Definition is a workflow of steps linked by success/failure transitions.
+// Steps that no transition points to are the entry points of a run.
+type Definition struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+}
+
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunCancelled RunStatus = "cancelled"
+)
+
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"
+	StepRunning   StepStatus = "running"
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped" // No transition taken leads here
+)
+
+// StepRun is the outcome of one step within a run. StartedAt and FinishedAt
+// bound the log entries of the step's process that belong to this run.
+type StepRun struct {
+	StepID     string     `json:"stepId"`
+	ProcessID  string     `json:"processId"`
+	Status     StepStatus `json:"status"`
+	ExitCode   *int       `json:"exitCode,omitempty"`
+	Error      string     `json:"error"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Run is one execution of a pipeline.
+type Run struct {
+	ID         string     `json:"id"`
+	PipelineID string     `json:"pipelineId"`
+	Status     RunStatus  `json:"status"`
+	Steps      []StepRun  `json:"steps"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
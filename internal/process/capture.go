@@ -0,0 +1,187 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxLineBytes = 64 << 10
+	readBufferSize      = 32 << 10
+
+	// continuedMarker ends a piece of a line that was split; the rest of
+	// the line follows in the next entry
+	continuedMarker = " [...]"
+)
+
+// LongLinePolicy decides what happens to output lines over the length limit
+type LongLinePolicy string
+
+const (
+	LongLinesSplit    LongLinePolicy = "split"    // Break the line into several entries (default)
+	LongLinesTruncate LongLinePolicy = "truncate" // Keep the start of the line and drop the rest
+)
+
+// OutputPolicy controls how the output of a process is cut into lines.
+type OutputPolicy struct {
+	MaxLineBytes int            `json:"maxLineBytes"` // Defaults to 64KB
+	LongLines    LongLinePolicy `json:"longLines"`
+}
+
+// lineSplitter cuts output into lines. Unlike bufio.Scanner it never gives
+// up on a long line: the line is split or truncated and reading goes on. A
+// carriage return that is not part of a CRLF starts the line over, the way
+// a terminal shows progress bars, so only the final state is kept.
+type lineSplitter struct {
+	max      int
+	truncate bool
+	emit     func(line string)
+
+	line      []byte
+	truncated int  // bytes dropped from line
+	cr        bool // the previous chunk ended in \r
+}
+
+// readLines reads r until it fails or ends, passing each line to emit. A
+// final line without a newline is passed too.
+func readLines(r io.Reader, policy OutputPolicy, emit func(line string)) {
+	s := &lineSplitter{
+		max:      policy.MaxLineBytes,
+		truncate: policy.LongLines == LongLinesTruncate,
+		emit:     emit,
+	}
+	if s.max <= 0 {
+		s.max = defaultMaxLineBytes
+	}
+	// Markers must fit within a line
+	s.max = max(s.max, 64)
+
+	br := bufio.NewReaderSize(r, readBufferSize)
+	for {
+		chunk, err := br.ReadSlice('\n')
+		complete := err == nil
+		if complete {
+			chunk = chunk[:len(chunk)-1]
+		}
+		s.write(chunk, complete)
+		if complete {
+			s.flush()
+			continue
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			// A partial final line
+			if len(s.line) > 0 || s.truncated > 0 {
+				s.flush()
+			}
+			return
+		}
+	}
+}
+
+// write adds a chunk of the current line, which ends the line when complete
+func (s *lineSplitter) write(chunk []byte, complete bool) {
+	if s.cr {
+		s.cr = false
+		if !(complete && len(chunk) == 0) {
+			// Not a CRLF split across reads after all
+			s.reset()
+		}
+	}
+	if complete {
+		chunk = bytes.TrimSuffix(chunk, []byte{'\r'})
+	} else if bytes.HasSuffix(chunk, []byte{'\r'}) {
+		// It may be the first half of a CRLF
+		chunk = chunk[:len(chunk)-1]
+		s.cr = true
+	}
+	if i := bytes.LastIndexByte(chunk, '\r'); i >= 0 {
+		s.reset()
+		chunk = chunk[i+1:]
+	}
+
+	for len(chunk) > 0 {
+		room := s.max - len(s.line)
+		if len(chunk) <= room {
+			s.line = append(s.line, chunk...)
+			return
+		}
+		if s.truncate {
+			cut := runeBoundary(chunk, room)
+			s.line = append(s.line, chunk[:cut]...)
+			s.truncated += len(chunk) - cut
+			return
+		}
+		cut := runeBoundary(chunk, room-len(continuedMarker))
+		s.line = append(s.line, chunk[:cut]...)
+		s.emit(escapeOutput(s.line) + continuedMarker)
+		s.line = s.line[:0]
+		chunk = chunk[cut:]
+	}
+}
+
+// flush emits the current line
+func (s *lineSplitter) flush() {
+	line := escapeOutput(s.line)
+	if s.truncated > 0 {
+		line += " [... " + strconv.Itoa(s.truncated) + " bytes truncated]"
+	}
+	s.emit(line)
+	s.reset()
+}
+
+func (s *lineSplitter) reset() {
+	s.line = s.line[:0]
+	s.truncated = 0
+}
+
+// runeBoundary moves a cut at n back to the start of a UTF-8 sequence, so
+// that splitting does not garble a character
+func runeBoundary(b []byte, n int) int {
+	n = max(min(n, len(b)), 0)
+	for i := n; i > 0 && i > n-utf8.UTFMax; i-- {
+		if i == len(b) || utf8.RuneStart(b[i]) {
+			return i
+		}
+	}
+	return n
+}
+
+// escapeOutput turns a line into valid UTF-8 text: invalid bytes and
+// control characters, as found in binary output, are written as \xNN.
+// Tabs and the escape character of ANSI colors are kept.
+func escapeOutput(b []byte) string {
+	clean := true
+	for _, c := range b {
+		if c >= utf8.RuneSelf || isControl(c) {
+			clean = false
+			break
+		}
+	}
+	if clean || (utf8.Valid(b) && !bytes.ContainsFunc(b, func(r rune) bool { return r < utf8.RuneSelf && isControl(byte(r)) })) {
+		return string(b)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(b) + 16)
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if (r == utf8.RuneError && size == 1) || (r < utf8.RuneSelf && isControl(byte(r))) {
+			sb.WriteString(`\x`)
+			sb.WriteByte("0123456789abcdef"[b[0]>>4])
+			sb.WriteByte("0123456789abcdef"[b[0]&0xf])
+		} else {
+			sb.Write(b[:size])
+		}
+		b = b[size:]
+	}
+	return sb.String()
+}
+
+func isControl(c byte) bool {
+	return (c < 0x20 && c != '\t' && c != 0x1b) || c == 0x7f
+}
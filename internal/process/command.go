@@ -0,0 +1,32 @@
+package process
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// buildCommand creates the command for def. In shell mode the command line
+// is handed to a shell so pipes, redirects and globbing work; the shell is
+// started in its own process group like any other command, so stopping it
+// terminates the whole pipeline.
+func buildCommand(ctx context.Context, def Definition) *exec.Cmd {
+	if !def.ShellMode {
+		return exec.CommandContext(ctx, def.Command, def.Args...)
+	}
+	shell := def.Shell
+	if shell == "" {
+		shell = defaultShell(def.LoginShell)
+	}
+	return exec.CommandContext(ctx, shell, shellArgs(def.LoginShell, commandLine(def))...)
+}
+
+// commandLine joins Command and Args into the line run by the shell. Args
+// are appended verbatim so a command line split across both fields still
+// reads the way it was typed.
+func commandLine(def Definition) string {
+	parts := make([]string, 0, len(def.Args)+1)
+	parts = append(parts, def.Command)
+	parts = append(parts, def.Args...)
+	return strings.Join(parts, " ")
+}
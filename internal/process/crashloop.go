@@ -0,0 +1,78 @@
+package process
+
+import "time"
+
+const (
+	defaultCrashLoopFailures = 5
+	defaultCrashLoopWindow   = time.Minute
+	defaultCrashLoopCooldown = 5 * time.Minute
+)
+
+// crashLoop tracks the recent failures of a process
+type crashLoop struct {
+	failures     []time.Time
+	trips        int
+	backoffUntil *time.Time
+}
+
+// limits resolves the policy against the defaults. A negative cooldown
+// means the process waits for the user after a crash loop.
+func (p CrashLoopPolicy) limits() (maxFailures int, window, cooldown time.Duration) {
+	maxFailures, window, cooldown = p.MaxFailures, time.Duration(p.Window)*time.Second, time.Duration(p.Cooldown)*time.Second
+	if maxFailures == 0 {
+		maxFailures = defaultCrashLoopFailures
+	}
+	if window <= 0 {
+		window = defaultCrashLoopWindow
+	}
+	if cooldown == 0 {
+		cooldown = defaultCrashLoopCooldown
+	}
+	return maxFailures, window, cooldown
+}
+
+// recordFailure notes a failed run and reports whether the failures within
+// the window have reached the limit, in which case the counter starts over.
+func (c *crashLoop) recordFailure(policy CrashLoopPolicy, now time.Time) bool {
+	maxFailures, window, _ := policy.limits()
+	if maxFailures < 0 {
+		return false
+	}
+
+	c.failures = append(c.failures, now)
+	c.prune(window, now)
+	if len(c.failures) < maxFailures {
+		return false
+	}
+	c.failures = nil
+	c.trips++
+	return true
+}
+
+// prune drops failures that fell out of the window
+func (c *crashLoop) prune(window time.Duration, now time.Time) {
+	cutoff := now.Add(-window)
+	kept := c.failures[:0]
+	for _, t := range c.failures {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	c.failures = kept
+}
+
+func (c *crashLoop) state(policy CrashLoopPolicy) CrashLoopState {
+	_, window, _ := policy.limits()
+	failures := 0
+	cutoff := time.Now().Add(-window)
+	for _, t := range c.failures {
+		if t.After(cutoff) {
+			failures++
+		}
+	}
+	return CrashLoopState{
+		Failures:     failures,
+		Trips:        c.trips,
+		BackoffUntil: c.backoffUntil,
+	}
+}
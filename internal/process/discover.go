@@ -0,0 +1,153 @@
+package process
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrProcessNotFound = errors.New("system process not found")
+
+// SystemProcess is a process running on the system, offered for import as a
+// definition.
+type SystemProcess struct {
+	PID         int         `json:"pid"`
+	PPID        int         `json:"ppid"`
+	Name        string      `json:"name"`
+	CommandLine string      `json:"commandLine"`
+	Args        []string    `json:"args"`                 // Command line split into words, starting with the command
+	WorkingDir  string      `json:"workingDir,omitempty"` // Empty when not readable
+	Env         Environment `json:"env,omitempty"`        // Empty when not readable
+	Ports       []int       `json:"ports,omitempty"`      // TCP ports it listens on
+	StartedAt   time.Time   `json:"startedAt"`
+	ManagedBy   string      `json:"managedBy,omitempty"` // ID of the managed process it belongs to
+}
+
+// SystemProcesses lists the processes of the current user, leaving out
+// ProcHub itself. Processes started by ProcHub, or by its processes, name
+// the managed process they belong to.
+func (m *Manager) SystemProcesses() ([]SystemProcess, error) {
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	managed := make(map[int]string, len(m.entries))
+	for id, item := range m.entries {
+		if pid := item.pid(); pid != 0 {
+			managed[pid] = id
+		}
+	}
+	m.mu.RUnlock()
+
+	parents := make(map[int]int, len(procs))
+	for _, p := range procs {
+		parents[p.PID] = p.PPID
+	}
+	managedBy := func(pid int) string {
+		// Bounded in case the table changed under us and forms a cycle
+		for i := 0; pid > 1 && i < len(parents); i++ {
+			if id, ok := managed[pid]; ok {
+				return id
+			}
+			pid = parents[pid]
+		}
+		return ""
+	}
+
+	self := os.Getpid()
+	ports := listeningPorts()
+	result := make([]SystemProcess, 0, len(procs))
+	for _, p := range procs {
+		if p.PID == self || !ownProcess(p.PID) {
+			continue
+		}
+		sp := systemProcess(p)
+		sp.Ports = ports[p.PID]
+		sp.ManagedBy = managedBy(p.PID)
+		result = append(result, sp)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+		}
+		return result[i].PID < result[j].PID
+	})
+	return result, nil
+}
+
+// SystemProcess returns a single process of the system by PID
+func (m *Manager) SystemProcess(pid int) (SystemProcess, error) {
+	procs, err := listProcesses()
+	if err != nil {
+		return SystemProcess{}, err
+	}
+	for _, p := range procs {
+		if p.PID == pid {
+			sp := systemProcess(p)
+			sp.Ports = listeningPorts()[pid]
+			return sp, nil
+		}
+	}
+	return SystemProcess{}, ErrProcessNotFound
+}
+
+func systemProcess(p procInfo) SystemProcess {
+	args, dir, env := processDetails(p)
+	return SystemProcess{
+		PID:         p.PID,
+		PPID:        p.PPID,
+		Name:        p.Name,
+		CommandLine: p.CommandLine,
+		Args:        args,
+		WorkingDir:  dir,
+		Env:         env,
+		StartedAt:   p.StartedAt,
+	}
+}
+
+// DraftDefinition turns a system process into a definition to review before
+// adding it. Only the environment variables that differ from ProcHub's own
+// are kept, as the rest is inherited anyway.
+func DraftDefinition(p SystemProcess) Definition {
+	def := Definition{
+		Name:       p.Name,
+		WorkingDir: p.WorkingDir,
+		Kind:       KindService,
+	}
+	if len(p.Args) > 0 {
+		def.Command = p.Args[0]
+		def.Args = append([]string(nil), p.Args[1:]...)
+		if def.Name == "" {
+			def.Name = strings.TrimSuffix(filepath.Base(def.Command), filepath.Ext(def.Command))
+		}
+	}
+
+	env := make(Environment)
+	for key, value := range p.Env {
+		if own, ok := os.LookupEnv(key); !ok || own != value {
+			env[key] = value
+		}
+	}
+	if len(env) > 0 {
+		def.Env = env
+	}
+	return def
+}
+
+// appendPort adds port to a sorted list unless it is there already, as
+// IPv4 and IPv6 listeners often share a port
+func appendPort(ports []int, port int) []int {
+	i := sort.SearchInts(ports, port)
+	if i < len(ports) && ports[i] == port {
+		return ports
+	}
+	ports = append(ports, 0)
+	copy(ports[i+1:], ports[i:])
+	ports[i] = port
+	return ports
+}
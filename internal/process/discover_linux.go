@@ -0,0 +1,75 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processDetails reads the argument vector, working directory and
+// environment of a process from /proc, leaving out what is not readable
+func processDetails(p procInfo) ([]string, string, Environment) {
+	dir := "/proc/" + strconv.Itoa(p.PID)
+	var args []string
+	if data, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		args = strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	}
+	cwd, _ := os.Readlink(filepath.Join(dir, "cwd"))
+
+	var env Environment
+	if data, err := os.ReadFile(filepath.Join(dir, "environ")); err == nil && len(data) > 0 {
+		env = make(Environment)
+		for _, pair := range strings.Split(strings.TrimRight(string(data), "\x00"), "\x00") {
+			if key, value, ok := strings.Cut(pair, "="); ok && key != "" {
+				env[key] = value
+			}
+		}
+	}
+	return args, cwd, env
+}
+
+// ownProcess reports whether pid belongs to the current user
+func ownProcess(pid int) bool {
+	info, err := os.Stat("/proc/" + strconv.Itoa(pid))
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
+// listeningPorts maps PIDs to the TCP ports they listen on. Sockets of other
+// users cannot be traced to their process and are left out.
+func listeningPorts() map[int][]int {
+	inodes := make(map[string]int)
+	scanTCP(func(port int, state, inode string) {
+		if state == tcpListen {
+			inodes["socket:["+inode+"]"] = port
+		}
+	})
+
+	ports := make(map[int][]int)
+	if len(inodes) == 0 {
+		return ports
+	}
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err != nil {
+			continue
+		}
+		port, ok := inodes[target]
+		if !ok {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.Split(fd, "/")[2])
+		if err == nil {
+			ports[pid] = appendPort(ports[pid], port)
+		}
+	}
+	return ports
+}
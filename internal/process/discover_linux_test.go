@@ -0,0 +1,54 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverAndDraft(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sleep", "39")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PROCHUB_DISCOVER=1")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start sleep failed: %v", err)
+	}
+	go cmd.Wait()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	m := NewManager()
+	procs, err := m.SystemProcesses()
+	if err != nil {
+		t.Fatalf("SystemProcesses failed: %v", err)
+	}
+	var found SystemProcess
+	for _, p := range procs {
+		if p.PID == cmd.Process.Pid {
+			found = p
+		}
+	}
+	if found.PID == 0 {
+		t.Fatalf("pid %d not listed", cmd.Process.Pid)
+	}
+
+	def := DraftDefinition(found)
+	want := Definition{
+		Name:       "sleep",
+		Command:    "sleep",
+		Args:       []string{"39"},
+		WorkingDir: dir,
+		Env:        Environment{"PROCHUB_DISCOVER": "1"},
+		Kind:       KindService,
+	}
+	if !reflect.DeepEqual(def, want) {
+		t.Fatalf("DraftDefinition() = %+v, want %+v", def, want)
+	}
+}
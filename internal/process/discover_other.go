@@ -0,0 +1,44 @@
+//go:build !linux && !windows
+
+package process
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processDetails splits the command line reported by ps. The working
+// directory and environment of other processes are not readable here.
+func processDetails(p procInfo) ([]string, string, Environment) {
+	return strings.Fields(p.CommandLine), "", nil
+}
+
+// ownProcess reports whether pid belongs to the current user: signalling
+// the processes of other users is not permitted
+func ownProcess(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
+// listeningPorts maps PIDs to the TCP ports they listen on
+func listeningPorts() map[int][]int {
+	ports := make(map[int][]int)
+	out, err := exec.Command("lsof", "-nP", "-iTCP", "-sTCP:LISTEN", "-Fpn").Output()
+	if err != nil {
+		return ports
+	}
+	pid := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "p"):
+			pid, _ = strconv.Atoi(line[1:])
+		case strings.HasPrefix(line, "n") && pid != 0:
+			addr := line[1:]
+			if port, err := strconv.Atoi(addr[strings.LastIndexByte(addr, ':')+1:]); err == nil {
+				ports[pid] = appendPort(ports[pid], port)
+			}
+		}
+	}
+	return ports
+}
@@ -0,0 +1,51 @@
+//go:build windows
+
+package process
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// processDetails splits the command line the way the process itself did.
+// The working directory and environment of other processes are not
+// readable here.
+func processDetails(p procInfo) ([]string, string, Environment) {
+	args, err := windows.DecomposeCommandLine(p.CommandLine)
+	if err != nil {
+		args = strings.Fields(p.CommandLine)
+	}
+	return args, "", nil
+}
+
+// ownProcess reports whether pid runs in the session of the current user,
+// which leaves out services
+func ownProcess(pid int) bool {
+	var own, session uint32
+	if windows.ProcessIdToSessionId(windows.GetCurrentProcessId(), &own) != nil {
+		return true
+	}
+	return windows.ProcessIdToSessionId(uint32(pid), &session) == nil && session == own
+}
+
+// listeningPorts maps PIDs to the TCP ports they listen on
+func listeningPorts() map[int][]int {
+	ports := make(map[int][]int)
+	rows, _ := netstat()
+	for _, fields := range rows {
+		if fields[3] != "LISTENING" {
+			continue
+		}
+		addr := fields[1]
+		port, err := strconv.Atoi(addr[strings.LastIndexByte(addr, ':')+1:])
+		if err != nil {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[4]); err == nil {
+			ports[pid] = appendPort(ports[pid], port)
+		}
+	}
+	return ports
+}
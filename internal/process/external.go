@@ -0,0 +1,249 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoExternalMatch = errors.New("external process has no pid file, pid or pattern")
+	ErrNotExternal     = errors.New("process is not external")
+	// ErrExternalPIDCommand is returned for an external process found by a
+	// fixed PID that also has a Command: a relaunched process gets another
+	// PID and would never be found
+	ErrExternalPIDCommand = errors.New("an external process found by pid cannot be relaunched, use a pid file or pattern")
+	// ErrExternalGroup is returned for group signals to an external
+	// process, whose group ProcHub did not create and may hold others
+	ErrExternalGroup = errors.New("cannot signal the process group of an external process")
+)
+
+// defaultExternalPoll is how often an external process is looked for
+const defaultExternalPoll = 2 * time.Second
+
+// findExternal returns the PID of the process matched by match, or 0 when
+// it is not running
+func findExternal(match ExternalMatch) (int, error) {
+	switch {
+	case match.PIDFile != "":
+		data, err := os.ReadFile(match.PIDFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return 0, fmt.Errorf("invalid pid file %s: %w", match.PIDFile, err)
+		}
+		if !isProcessRunning(pid) {
+			return 0, nil
+		}
+		return pid, nil
+	case match.PID > 0:
+		if !isProcessRunning(match.PID) {
+			return 0, nil
+		}
+		return match.PID, nil
+	case match.Pattern != "":
+		pattern, err := regexp.Compile(match.Pattern)
+		if err != nil {
+			return 0, err
+		}
+		procs, err := listProcesses()
+		if err != nil {
+			return 0, err
+		}
+		return matchProcess(procs, pattern, os.Getpid()), nil
+	}
+	return 0, ErrNoExternalMatch
+}
+
+// matchProcess picks the process whose command line matches pattern. When a
+// process and its children match, such as a shell and the command it runs,
+// the topmost one wins, then the oldest.
+func matchProcess(procs []procInfo, pattern *regexp.Regexp, self int) int {
+	matched := make(map[int]procInfo)
+	for _, p := range procs {
+		if p.PID != self && pattern.MatchString(p.CommandLine) {
+			matched[p.PID] = p
+		}
+	}
+	best := procInfo{}
+	for _, p := range matched {
+		if _, parentMatched := matched[p.PPID]; parentMatched {
+			continue
+		}
+		if best.PID == 0 || p.StartedAt.Before(best.StartedAt) || (p.StartedAt.Equal(best.StartedAt) && p.PID < best.PID) {
+			best = p
+		}
+	}
+	return best.PID
+}
+
+// Watch monitors an external process without launching it when it is not
+// running, unlike Start.
+func (m *Manager) Watch(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if item.definition.Kind != KindExternal {
+		return ErrNotExternal
+	}
+	if item.watchingExternal() {
+		return nil
+	}
+	item.status = StatusStopped
+	item.manuallyStopped = false
+	done := make(chan struct{})
+	item.done = done
+	go func() {
+		defer close(done)
+		m.watchExternal(ctx, id, done, false)
+	}()
+	return nil
+}
+
+// watchExternal monitors an external process until the watch is stopped,
+// reporting it as running while it is found. When it disappears and the
+// definition has a Command, the restart policy decides whether to launch it
+// again; with launch set it is also launched if it is not running at first.
+func (m *Manager) watchExternal(ctx context.Context, id string, done chan struct{}, launch bool) {
+	for first := true; ; first = false {
+		m.mu.Lock()
+		item, ok := m.entries[id]
+		if !ok || item.done != done || item.manuallyStopped {
+			m.mu.Unlock()
+			return
+		}
+		def := item.definition
+		m.mu.Unlock()
+
+		pid, err := findExternal(def.External)
+
+		m.mu.Lock()
+		if item.done != done || item.manuallyStopped {
+			m.mu.Unlock()
+			return
+		}
+		wake := item.wake
+		lost := false
+		switch {
+		case pid != 0:
+			if item.externalPID != pid {
+				item.externalPID = pid
+				startedAt := time.Now()
+				if usage, err := processUsage(pid); err == nil && !usage.StartedAt.IsZero() {
+					startedAt = usage.StartedAt
+				}
+				item.startedAt = &startedAt
+				item.stoppedAt = nil
+			}
+			item.status = StatusRunning
+			item.lastError = ""
+		case item.externalPID != 0:
+			lost = true
+			stoppedAt := time.Now()
+			item.stoppedAt = &stoppedAt
+			item.externalPID = 0
+			item.lastError = "external process exited"
+			item.status = StatusStopped
+		default:
+			item.status = StatusStopped
+			if err != nil {
+				item.lastError = err.Error()
+			}
+		}
+		m.mu.Unlock()
+
+		if first && launch && pid == 0 && err == nil && def.Command != "" {
+			m.launchExternal(id, def)
+		} else if lost && def.Command != "" && m.shouldRestart(id, -1, false) {
+			if !m.checkCrashLoop(ctx, id, done, false) {
+				return
+			}
+			m.launchExternal(id, def)
+		}
+
+		select {
+		case <-time.After(m.externalPoll):
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// launchExternal runs the Command of an external definition to bring the
+// process back. It is not tracked as our child: the watcher finds it again
+// like any other external process. Its output is discarded rather than
+// piped to ProcHub and it runs in its own process group, so it outlives
+// ProcHub.
+func (m *Manager) launchExternal(id string, def Definition) {
+	err := m.allocatePorts(def)
+	resolved := def
+	if err == nil {
+		m.mu.RLock()
+		resolved, err = m.resolve(def)
+		m.mu.RUnlock()
+	}
+	if def.External.PIDFile == "" && def.External.PID > 0 {
+		err = ErrExternalPIDCommand
+	}
+	if err == nil {
+		err = startDetached(resolved)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.entries[id]
+	switch {
+	case !ok:
+	case err != nil:
+		item.lastError = "restart command failed: " + err.Error()
+	case item.externalPID == 0:
+		item.status = StatusStarting
+	}
+}
+
+// startDetached starts def with no ties to ProcHub: stdin and output go to
+// the null device and the process gets its own process group
+func startDetached(def Definition) error {
+	cmd := buildCommand(context.Background(), def)
+	cmd.Dir = def.WorkingDir
+	cmd.Env = append(os.Environ(), envFromMap(def.Env)...)
+	setupProcessGroup(cmd)
+	if def.ShellMode {
+		setShellCmdLine(cmd, commandLine(def))
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap it should it exit while ProcHub runs
+	go cmd.Wait()
+	return nil
+}
+
+// stopPID gracefully stops a single process we did not start, force
+// killing it after GracefulStopTimeout
+func stopPID(pid int) error {
+	_ = sendSignal(pid, "TERM", false)
+	deadline := time.Now().Add(GracefulStopTimeout)
+	for time.Now().Before(deadline) {
+		if !isProcessRunning(pid) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return sendSignal(pid, "KILL", false)
+}
@@ -0,0 +1,162 @@
+package process
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+const defaultIdleTimeout = 10 * time.Minute
+
+// defaultIdleCheck is how often a running lazy process is checked for
+// connections
+const defaultIdleCheck = 5 * time.Second
+
+// idleTimeout resolves IdleTimeout against the default; zero means the
+// process is never stopped for being idle
+func (d Definition) idleTimeout() time.Duration {
+	switch {
+	case d.IdleTimeout < 0:
+		return 0
+	case d.IdleTimeout == 0:
+		return defaultIdleTimeout
+	}
+	return time.Duration(d.IdleTimeout) * time.Second
+}
+
+// Acquire records a connection to the process, such as a proxied request.
+// An idle lazy process is started first, and Acquire waits until it is
+// ready. The returned release must be called once the connection closes.
+func (m *Manager) Acquire(ctx context.Context, id string) (release func(), err error) {
+	m.mu.Lock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if item.status == StatusIdle {
+		// Not tied to ctx, which only covers this connection
+		m.startLocked(context.Background(), id, item)
+	}
+	m.mu.Unlock()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		m.mu.Lock()
+		item, ok = m.entries[id]
+		if !ok {
+			m.mu.Unlock()
+			return nil, ErrNotFound
+		}
+		if item.status == StatusRunning {
+			break
+		}
+		if item.status != StatusStarting && item.status != StatusBackoff {
+			m.mu.Unlock()
+			return nil, ErrNotRunning
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	item.connections++
+	item.touch()
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		item.connections--
+		item.touch()
+	}, nil
+}
+
+func (e *entry) touch() {
+	now := time.Now()
+	e.lastActivity = &now
+}
+
+// watchIdle stops a lazy process once it has had no connections for its
+// idle timeout. It returns when inst is no longer the running instance.
+func (m *Manager) watchIdle(id string, inst *instance, def Definition) {
+	timeout := def.idleTimeout()
+	if timeout == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	if item, ok := m.entries[id]; ok {
+		item.touch()
+	}
+	m.mu.Unlock()
+
+	ticker := time.NewTicker(m.idleCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-inst.exited:
+			return
+		}
+
+		m.mu.Lock()
+		item, ok := m.entries[id]
+		if !ok || item.inst != inst || item.status != StatusRunning {
+			m.mu.Unlock()
+			if ok && item.status == StatusStarting {
+				continue
+			}
+			return
+		}
+		ports := item.ports(m.ports.peek(id))
+		busy := item.connections > 0
+		m.mu.Unlock()
+
+		// Connections accepted by the process itself, e.g. on held sockets.
+		// Where they cannot be counted, the process is kept running rather
+		// than cutting them off.
+		if !busy {
+			for _, port := range ports {
+				if count, ok := connectionCount(port); !ok || count > 0 {
+					busy = true
+					break
+				}
+			}
+		}
+
+		m.mu.Lock()
+		if busy {
+			item.touch()
+		}
+		idle := item.inst == inst && item.lastActivity != nil && time.Since(*item.lastActivity) >= timeout
+		m.mu.Unlock()
+
+		if idle {
+			_ = m.stop(id, StatusIdle)
+			return
+		}
+	}
+}
+
+// ports returns the TCP ports the process serves on: its allocated ports and
+// the sockets held for it. Callers must hold m.mu.
+func (e *entry) ports(allocated map[string]int) []int {
+	ports := make([]int, 0, len(allocated))
+	for _, port := range allocated {
+		ports = append(ports, port)
+	}
+	for _, addr := range e.listening() {
+		if _, p, err := net.SplitHostPort(addr); err == nil {
+			if port, err := strconv.Atoi(p); err == nil {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
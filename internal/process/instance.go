@@ -0,0 +1,198 @@
+package process
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// outputDrainTimeout bounds how long an exited process's remaining output
+// is awaited before the exit is reported
+const outputDrainTimeout = time.Second
+
+// instance is one live copy of a process. An entry normally has one; during
+// a rolling restart a second one runs until the handover completes.
+type instance struct {
+	cmd       *exec.Cmd
+	startedAt time.Time
+	exited    chan struct{} // closed once cmd.Wait has returned
+	err       error         // result of cmd.Wait, valid once exited is closed
+
+	readyPattern *regexp.Regexp // log readiness pattern, if any
+	ready        chan struct{}  // closed when readyPattern matches
+	readyOnce    sync.Once
+
+	logs *logQueue // output read but not yet passed to the log callback
+}
+
+// spawn starts a new instance of def and streams its output. It must be
+// called without m.mu held, as binding sockets, starting the command and
+// recording it in the state file may be slow; callers publish the instance
+// afterwards.
+func (m *Manager) spawn(ctx context.Context, id string, def Definition) (*instance, error) {
+	m.mu.RLock()
+	item, ok := m.entries[id]
+	state := m.state
+	logCb := m.logCallback
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	cmd := buildCommand(ctx, def)
+	cmd.Dir = def.WorkingDir
+	cmd.Env = append(cmd.Env, os.Environ()...)
+	cmd.Env = append(cmd.Env, envFromMap(def.Env)...)
+
+	sockets, err := m.sockets(id)
+	if err != nil {
+		return nil, err
+	}
+	if sockets != nil {
+		attachSockets(cmd, sockets)
+	}
+
+	// Set up platform-specific process group for proper child process handling
+	setupProcessGroup(cmd)
+	if def.ShellMode {
+		setShellCmdLine(cmd, commandLine(def))
+	}
+	// Non-detached children must not outlive a crashed ProcHub
+	if !def.Detached {
+		setParentDeathSignal(cmd)
+	}
+
+	// Capture stdout and stderr. Unlike StdoutPipe these pipes are not
+	// closed by cmd.Wait, so the last lines of a short-lived process are
+	// never lost.
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
+		return nil, err
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+
+	err = startCmd(cmd)
+	// The child holds its own copies of the write ends
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, err
+	}
+
+	inst := &instance{
+		cmd:       cmd,
+		startedAt: time.Now(),
+		exited:    make(chan struct{}),
+		ready:     make(chan struct{}),
+	}
+	if def.Readiness.Type == ReadyLog {
+		// An invalid pattern is reported by waitReady
+		inst.readyPattern, _ = regexp.Compile(def.Readiness.Target)
+	}
+
+	if state != nil && !def.Detached {
+		state.put(stateRecord{
+			ID:         id,
+			Name:       def.Name,
+			PID:        cmd.Process.Pid,
+			Command:    def.Command,
+			StartedAt:  inst.startedAt,
+			StartTicks: processStartTicks(cmd.Process.Pid),
+		})
+	}
+
+	inst.logs = newLogQueue(def.LogQueue, &item.droppedLines, func(stream, line string, at time.Time) {
+		if logCb != nil {
+			logCb(id, stream, line, at)
+		}
+	})
+
+	var streams sync.WaitGroup
+	streams.Add(2)
+	go func() {
+		defer streams.Done()
+		defer stdout.Close()
+		m.streamOutput(stdout, "stdout", inst, def)
+	}()
+	go func() {
+		defer streams.Done()
+		defer stderr.Close()
+		m.streamOutput(stderr, "stderr", inst, def)
+	}()
+	go func() {
+		streams.Wait()
+		inst.logs.close()
+	}()
+
+	go func() {
+		inst.err = cmd.Wait()
+		// Let the readers and the log queue catch up, unless a leftover
+		// child keeps the pipes open
+		select {
+		case <-inst.logs.done:
+		case <-time.After(outputDrainTimeout):
+		}
+		if state != nil && !def.Detached {
+			state.remove(id, cmd.Process.Pid)
+		}
+		close(inst.exited)
+	}()
+	return inst, nil
+}
+
+// observe checks an output line against the log readiness pattern
+func (inst *instance) observe(line string) {
+	if inst.readyPattern != nil && inst.readyPattern.MatchString(line) {
+		inst.readyOnce.Do(func() { close(inst.ready) })
+	}
+}
+
+// stopCmd stops a process gracefully, force killing it after
+// GracefulStopTimeout.
+func stopCmd(cmd *exec.Cmd) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	// Try graceful stop first
+	done := make(chan struct{})
+	go func() {
+		gracefulStop(cmd, done)
+	}()
+
+	// Wait for graceful stop or timeout
+	select {
+	case <-done:
+		// Wait a bit for the process to exit gracefully
+		timer := time.NewTimer(GracefulStopTimeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				// Timeout, force kill
+				return killProcess(cmd)
+			default:
+				// Wait for the whole group, e.g. every stage of a shell pipeline
+				if !isProcessGroupAlive(cmd.Process.Pid) {
+					return nil
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+	case <-time.After(GracefulStopTimeout):
+		return killProcess(cmd)
+	}
+}
@@ -0,0 +1,162 @@
+package process
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultLogQueueSize = 10000
+	// logSampleRate is the share of lines the sample policy keeps while the
+	// queue is full: one in logSampleRate
+	logSampleRate = 10
+)
+
+// OverflowPolicy decides what happens to output lines when the log queue of
+// a process is full because the log sinks fall behind.
+type OverflowPolicy string
+
+const (
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // Make room by dropping the oldest queued line (default)
+	OverflowDropNewest OverflowPolicy = "drop_newest" // Drop the new line
+	OverflowSample     OverflowPolicy = "sample"      // Keep one new line in ten, dropping the oldest for it
+	OverflowBlock      OverflowPolicy = "block"       // Wait for room, which stalls the process once its pipe fills
+)
+
+// LogQueuePolicy bounds the lines read from a process but not yet handed to
+// the log callback.
+type LogQueuePolicy struct {
+	Size     int            `json:"size"`     // Lines held, defaults to 10000
+	Overflow OverflowPolicy `json:"overflow"` // What to drop when full, defaults to drop_oldest
+}
+
+type logLine struct {
+	stream string
+	line   string
+	at     time.Time // when the line was read
+}
+
+// logQueue sits between the output readers of an instance and the log
+// callback, so that slow log sinks do not stop the readers from draining
+// the pipes of the process.
+type logQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // signals new lines, free room and closing
+	lines   []logLine  // ring buffer
+	head    int
+	count   int
+	policy  OverflowPolicy
+	seen    int // lines offered while full, for sampling
+	closed  bool
+	dropped *atomic.Int64
+	done    chan struct{} // closed once the queue is drained after close
+}
+
+// newLogQueue starts a queue delivering lines to deliver and counting the
+// lines it drops in dropped
+func newLogQueue(policy LogQueuePolicy, dropped *atomic.Int64, deliver func(stream, line string, at time.Time)) *logQueue {
+	size := policy.Size
+	if size <= 0 {
+		size = defaultLogQueueSize
+	}
+	q := &logQueue{
+		lines:   make([]logLine, size),
+		policy:  policy.Overflow,
+		dropped: dropped,
+		done:    make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.run(deliver)
+	return q
+}
+
+// push queues a line read now
+func (q *logQueue) push(stream, line string) {
+	q.pushAt(stream, line, time.Now())
+}
+
+// pushAt queues a line read at a given time, applying the overflow policy
+// when the queue is full
+func (q *logQueue) pushAt(stream, line string, at time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.count == len(q.lines) {
+		switch q.policy {
+		case OverflowBlock:
+			for q.count == len(q.lines) && !q.closed {
+				q.cond.Wait()
+			}
+		case OverflowDropNewest:
+			q.dropped.Add(1)
+			return
+		case OverflowSample:
+			q.seen++
+			if q.seen%logSampleRate != 0 {
+				q.dropped.Add(1)
+				return
+			}
+			q.dropOldest()
+		default:
+			q.dropOldest()
+		}
+	} else {
+		q.seen = 0
+	}
+	if q.closed {
+		return
+	}
+
+	q.lines[(q.head+q.count)%len(q.lines)] = logLine{stream: stream, line: line, at: at}
+	q.count++
+	q.cond.Broadcast()
+}
+
+// dropOldest frees the slot of the oldest line. Callers must hold q.mu.
+func (q *logQueue) dropOldest() {
+	q.lines[q.head] = logLine{}
+	q.head = (q.head + 1) % len(q.lines)
+	q.count--
+	q.dropped.Add(1)
+}
+
+// run hands queued lines to deliver until the queue is closed and empty
+func (q *logQueue) run(deliver func(stream, line string, at time.Time)) {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		for q.count == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.count == 0 {
+			q.mu.Unlock()
+			return
+		}
+		next := q.lines[q.head]
+		q.lines[q.head] = logLine{}
+		q.head = (q.head + 1) % len(q.lines)
+		q.count--
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		if deliver != nil {
+			deliver(next.stream, next.line, next.at)
+		}
+	}
+}
+
+// close stops accepting lines; queued ones are still delivered
+func (q *logQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// backlog returns the number of lines waiting for delivery
+func (q *logQueue) backlog() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
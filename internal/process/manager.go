@@ -1,46 +1,94 @@
 package process
 
 import (
-	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	// GracefulStopTimeout is the time to wait for graceful process termination
 	GracefulStopTimeout = 5 * time.Second
+
+	// ReloadTimeout bounds how long a reload command may run
+	ReloadTimeout = 30 * time.Second
 )
 
 var (
-	ErrNotFound = errors.New("process not found")
+	ErrNotFound            = errors.New("process not found")
+	ErrOrphanRunning       = errors.New("process left by a previous session is still running")
+	ErrAlreadyRunning      = errors.New("process is already running")
+	ErrNotInCrashLoop      = errors.New("process is not in a crash loop")
+	ErrNotRunning          = errors.New("process is not running")
+	ErrNoReloadAction      = errors.New("process has no reload action")
+	ErrUnsupportedSignal   = errors.New("unsupported signal")
+	ErrHandoverRunning     = errors.New("a rolling restart is already in progress")
+	ErrRolledBack          = errors.New("rolling restart rolled back")
+	ErrUnknownOrphanPolicy = errors.New("unknown orphan policy")
 )
 
-// LogCallback is called when process outputs data
-type LogCallback func(processID, stream, line string)
+// LogCallback is called when process outputs data. at is when the line was
+// read, which may be a little before the call.
+type LogCallback func(processID, stream, line string, at time.Time)
+
+// AlertCallback is called when a process needs the user's attention, such as
+// when a crash loop is detected
+type AlertCallback func(processID, message string)
 
 type Manager struct {
-	mu          sync.RWMutex
-	entries     map[string]*entry
-	logCallback LogCallback
+	mu            sync.RWMutex
+	entries       map[string]*entry
+	logCallback   LogCallback
+	alertCallback AlertCallback
+	state         *stateFile
+	ports         *portAllocator
+	orphans       map[string]stateRecord
+	samples       map[int]cpuSample // last CPU sample per PID, for Stats
+	externalPoll  time.Duration     // how often external processes are looked for
+	idleCheck     time.Duration     // how often lazy processes are checked for connections
 }
 
 type entry struct {
 	definition      Definition
 	restarts        int
 	status          Status
-	cmd             *exec.Cmd
+	inst            *instance // current instance
+	standby         *instance // new instance waiting to become ready during a rolling restart
+	next            *instance // ready instance taking over once the current one exits
 	lastError       string
-	manuallyStopped bool // true when stopped by user, false when stopped automatically
+	manuallyStopped bool   // true when stopped by user, false when stopped automatically
+	stopStatus      Status // status a manual stop settles in
+	adoptedPID      int    // leader of a process group adopted from a previous session
+	externalPID     int    // process found for an external definition
+	startedAt       *time.Time
+	stoppedAt       *time.Time
+	exitCode        *int
+	duration        time.Duration // duration of the last finished run
+	done            chan struct{} // closed when the current run loop exits
+	crash           crashLoop
+	wake            chan struct{} // interrupts a crash-loop backoff
+	sockets         *socketSet    // bound listening sockets, nil until first needed
+	connections     int           // open connections through Acquire
+	lastActivity    *time.Time    // last connection seen, for idle stops
+	droppedLines    atomic.Int64  // output lines dropped by full log queues
 }
 
 func NewManager() *Manager {
 	return &Manager{
-		entries: make(map[string]*entry),
+		entries:      make(map[string]*entry),
+		orphans:      make(map[string]stateRecord),
+		ports:        newPortAllocator(),
+		samples:      make(map[int]cpuSample),
+		externalPoll: defaultExternalPoll,
+		idleCheck:    defaultIdleCheck,
 	}
 }
 
@@ -51,19 +99,187 @@ func (m *Manager) SetLogCallback(cb LogCallback) {
 	m.logCallback = cb
 }
 
+// SetAlertCallback sets the callback function for process alerts
+func (m *Manager) SetAlertCallback(cb AlertCallback) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alertCallback = cb
+}
+
+// SetStateFile sets the file used to remember live process groups across
+// sessions. It must be called before SweepOrphans and before any process is
+// started.
+func (m *Manager) SetStateFile(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = newStateFile(path)
+}
+
+// SetPortFile sets the file that keeps port allocations stable across
+// sessions and loads the allocations stored in it.
+func (m *Manager) SetPortFile(path string) {
+	m.ports.load(path)
+}
+
+// SetPortRange sets the range ports are allocated from. Ports allocated
+// before keep their values.
+func (m *Manager) SetPortRange(first, last int) {
+	m.ports.setRange(first, last)
+}
+
+// ParseOrphanPolicy checks a configured orphan policy. An empty one is
+// OrphanKill.
+func ParseOrphanPolicy(s string) (OrphanPolicy, error) {
+	switch policy := OrphanPolicy(s); policy {
+	case "":
+		return OrphanKill, nil
+	case OrphanKill, OrphanAdopt, OrphanKeep:
+		return policy, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownOrphanPolicy, s)
+}
+
+// SweepOrphans looks for process groups recorded by a previous session that
+// are still alive and applies policy to them. It returns the orphans that
+// were found, whatever was done with them. Groups are stopped in parallel,
+// so the sweep takes at most GracefulStopTimeout however many there are.
+func (m *Manager) SweepOrphans(policy OrphanPolicy) ([]Orphan, error) {
+	policy, err := ParseOrphanPolicy(string(policy))
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	state := m.state
+	m.mu.Unlock()
+	if state == nil {
+		return nil, nil
+	}
+
+	var stopping sync.WaitGroup
+	found := make([]Orphan, 0)
+	for _, rec := range state.load() {
+		if !isSameProcess(rec) {
+			continue
+		}
+		found = append(found, orphanFromRecord(rec))
+
+		m.mu.Lock()
+		_, known := m.entries[rec.ID]
+		m.mu.Unlock()
+
+		switch {
+		case policy == OrphanKill || !known:
+			stopping.Add(1)
+			go func(pid int) {
+				defer stopping.Done()
+				_ = stopProcessGroup(pid)
+			}(rec.PID)
+		default:
+			// Keep the record so the group is still found after another crash.
+			state.put(rec)
+			m.mu.Lock()
+			m.orphans[rec.ID] = rec
+			m.mu.Unlock()
+			if policy == OrphanAdopt {
+				_ = m.Adopt(rec.ID)
+			}
+		}
+	}
+	stopping.Wait()
+	return found, nil
+}
+
+// Orphans returns the leftover process groups that are waiting for the user
+// to kill or adopt them.
+func (m *Manager) Orphans() []Orphan {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orphans := make([]Orphan, 0, len(m.orphans))
+	for _, rec := range m.orphans {
+		orphans = append(orphans, orphanFromRecord(rec))
+	}
+	return orphans
+}
+
+// KillOrphan terminates a leftover process group from a previous session.
+func (m *Manager) KillOrphan(id string) error {
+	m.mu.Lock()
+	rec, ok := m.orphans[id]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(m.orphans, id)
+	state := m.state
+	m.mu.Unlock()
+
+	err := stopProcessGroup(rec.PID)
+	if state != nil {
+		state.remove(id, rec.PID)
+	}
+	return err
+}
+
+// Adopt takes a leftover process group from a previous session under
+// management. It is reported as running until the group exits and can be
+// stopped like any other process, but is not restarted when it exits.
+func (m *Manager) Adopt(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.orphans[id]
+	if !ok {
+		return ErrNotFound
+	}
+	item, ok := m.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if item.isActive() {
+		return ErrAlreadyRunning
+	}
+
+	delete(m.orphans, id)
+	item.adoptedPID = rec.PID
+	item.status = StatusRunning
+	item.lastError = ""
+	go m.watchAdopted(id, rec.PID)
+	return nil
+}
+
+// watchAdopted polls an adopted process group until it exits, since it is
+// not our child and cannot be waited on.
+func (m *Manager) watchAdopted(id string, pid int) {
+	for isProcessGroupAlive(pid) {
+		time.Sleep(time.Second)
+	}
+
+	m.mu.Lock()
+	item, ok := m.entries[id]
+	if ok && item.adoptedPID == pid {
+		item.adoptedPID = 0
+		if item.status == StatusRunning {
+			item.status = StatusStopped
+		}
+	}
+	state := m.state
+	m.mu.Unlock()
+
+	if state != nil {
+		state.remove(id, pid)
+	}
+}
+
 func (m *Manager) List() []Snapshot {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	snapshots := make([]Snapshot, 0, len(m.entries))
-	for _, item := range m.entries {
-		snapshots = append(snapshots, Snapshot{
-			Definition: item.definition,
-			PID:        pidOf(item.cmd),
-			Status:     item.status,
-			Restarts:   item.restarts,
-			LastError:  item.lastError,
-		})
+	for id, item := range m.entries {
+		snap := item.snapshot()
+		snap.Ports = m.ports.peek(id)
+		snapshots = append(snapshots, snap)
 	}
 	return snapshots
 }
@@ -72,10 +288,24 @@ func (m *Manager) Register(def Definition) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.entries[def.ID] = &entry{
+	item := &entry{
 		definition: def,
 		status:     StatusStopped,
+		wake:       make(chan struct{}, 1),
+	}
+	if def.Lazy {
+		// Waits for traffic rather than for the user
+		item.status = StatusIdle
 	}
+	// Keep bound sockets across a redefinition so queued connections survive
+	if old, ok := m.entries[def.ID]; ok && old.sockets != nil {
+		if sameSockets(old.definition, def) {
+			item.sockets = old.sockets
+		} else {
+			old.sockets.close()
+		}
+	}
+	m.entries[def.ID] = item
 }
 
 // SetAutoStart updates the auto-start flag of a process without disturbing a
@@ -100,9 +330,17 @@ func (m *Manager) Unregister(id string) error {
 		m.mu.Unlock()
 		return ErrNotFound
 	}
+	// Close the sockets first so an on-demand watcher cannot start it again
+	if item.sockets != nil {
+		item.sockets.close()
+		item.sockets = nil
+	}
 
-	// Stop the process if running
-	if item.status == StatusRunning || item.status == StatusStarting {
+	// Stop watching an external process, but leave it running
+	if item.definition.Kind == KindExternal {
+		item.manuallyStopped = true
+		item.interrupt()
+	} else if item.isActive() {
 		m.mu.Unlock()
 		_ = m.Stop(id)
 		m.mu.Lock()
@@ -110,6 +348,7 @@ func (m *Manager) Unregister(id string) error {
 
 	delete(m.entries, id)
 	m.mu.Unlock()
+	m.ports.release(id)
 	return nil
 }
 
@@ -123,21 +362,19 @@ func (m *Manager) Get(id string) (Snapshot, error) {
 		return Snapshot{}, ErrNotFound
 	}
 
-	return Snapshot{
-		Definition: item.definition,
-		PID:        pidOf(item.cmd),
-		Status:     item.status,
-		Restarts:   item.restarts,
-		LastError:  item.lastError,
-	}, nil
+	snap := item.snapshot()
+	snap.Ports = m.ports.peek(id)
+	return snap, nil
 }
 
-// StopAll stops all running processes
+// StopAll stops all running processes and returns once their remaining
+// output has been handed to the log callback
 func (m *Manager) StopAll() {
 	m.mu.RLock()
 	ids := make([]string, 0, len(m.entries))
 	for id, item := range m.entries {
-		if item.status == StatusRunning || item.status == StatusStarting {
+		// External processes outlive ProcHub
+		if item.isActive() && item.definition.Kind != KindExternal {
 			ids = append(ids, id)
 		}
 	}
@@ -156,19 +393,205 @@ func (m *Manager) Start(ctx context.Context, id string) error {
 		return ErrNotFound
 	}
 
-	if item.status == StatusRunning || item.status == StatusStarting {
+	if item.isActive() || item.watchingExternal() {
 		m.mu.Unlock()
 		return nil
 	}
+	if _, orphaned := m.orphans[id]; orphaned {
+		m.mu.Unlock()
+		return ErrOrphanRunning
+	}
 
+	m.startLocked(ctx, id, item)
+	m.mu.Unlock()
+	return nil
+}
+
+// startLocked starts the run loop of an inactive entry and returns the
+// channel closed when it ends. Callers must hold m.mu.
+func (m *Manager) startLocked(ctx context.Context, id string, item *entry) chan struct{} {
 	item.status = StatusStarting
+	item.crash.failures = nil
+	item.crash.backoffUntil = nil
+	done := make(chan struct{})
+	item.done = done
+
+	if item.definition.Kind == KindExternal {
+		item.manuallyStopped = false
+		go func() {
+			defer close(done)
+			m.watchExternal(ctx, id, done, true)
+		}()
+		return done
+	}
+
+	go func() {
+		defer close(done)
+		m.run(ctx, id)
+	}()
+	return done
+}
+
+// Wait blocks until the current run of the process ends, including any
+// restarts, and returns the final snapshot. It returns immediately when the
+// process is not running.
+func (m *Manager) Wait(ctx context.Context, id string) (Snapshot, error) {
+	m.mu.RLock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.RUnlock()
+		return Snapshot{}, ErrNotFound
+	}
+	done := item.done
+	m.mu.RUnlock()
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return Snapshot{}, ctx.Err()
+		}
+	}
+	return m.Get(id)
+}
+
+// RunNow starts a run of the process, refusing to start a second copy while
+// one is running. Unlike Start it resets the retry counter, which makes it
+// the natural action for tasks.
+func (m *Manager) RunNow(ctx context.Context, id string) error {
+	m.mu.Lock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	if item.isActive() {
+		m.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	item.restarts = 0
 	m.mu.Unlock()
 
-	go m.run(ctx, id)
+	return m.Start(ctx, id)
+}
+
+// Resume ends a crash-loop backoff early, or starts a process that was
+// stopped as fatal.
+func (m *Manager) Resume(ctx context.Context, id string) error {
+	m.mu.Lock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	status := item.status
+	m.mu.Unlock()
+
+	switch status {
+	case StatusBackoff:
+		item.interrupt()
+		return nil
+	case StatusFatal:
+		return m.Start(ctx, id)
+	}
+	return ErrNotInCrashLoop
+}
+
+// Signal sends the named signal (e.g. "HUP") to the running process, or to
+// its whole process group when group is set.
+func (m *Manager) Signal(id, signal string, group bool) error {
+	m.mu.RLock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.RUnlock()
+		return ErrNotFound
+	}
+	pid := item.pid()
+	running := item.status == StatusRunning
+	external := item.definition.Kind == KindExternal
+	m.mu.RUnlock()
+
+	if !running || pid == 0 {
+		return ErrNotRunning
+	}
+	if group && external {
+		return ErrExternalGroup
+	}
+	return sendSignal(pid, signal, group)
+}
+
+// Reload runs the reload action of a running process: it either sends the
+// configured signal or runs the configured command, whose output goes to the
+// process log on the "reload" stream.
+func (m *Manager) Reload(ctx context.Context, id string) error {
+	m.mu.RLock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.RUnlock()
+		return ErrNotFound
+	}
+	def := item.definition
+	pid := item.pid()
+	running := item.status == StatusRunning
+	logCb := m.logCallback
+	m.mu.RUnlock()
+
+	if !running || pid == 0 {
+		return ErrNotRunning
+	}
+
+	action := def.Reload
+	switch {
+	case action.Signal != "" && action.Group && def.Kind == KindExternal:
+		return ErrExternalGroup
+	case action.Signal != "":
+		return sendSignal(pid, action.Signal, action.Group)
+	case action.Command != "":
+		return runReloadCommand(ctx, id, def, pid, logCb)
+	}
+	return ErrNoReloadAction
+}
+
+// runReloadCommand runs def's reload command through the shell, with the
+// process's working directory and environment
+func runReloadCommand(ctx context.Context, id string, def Definition, pid int, logCb LogCallback) error {
+	ctx, cancel := context.WithTimeout(ctx, ReloadTimeout)
+	defer cancel()
+
+	reload := Definition{
+		Command:    def.Reload.Command,
+		ShellMode:  true,
+		Shell:      def.Shell,
+		LoginShell: def.LoginShell,
+	}
+	cmd := buildCommand(ctx, reload)
+	setShellCmdLine(cmd, commandLine(reload))
+	cmd.Dir = def.WorkingDir
+	cmd.Env = append(cmd.Env, os.Environ()...)
+	cmd.Env = append(cmd.Env, envFromMap(def.Env)...)
+	cmd.Env = append(cmd.Env, "PROCHUB_PID="+strconv.Itoa(pid))
+
+	output, err := cmd.CombinedOutput()
+	if logCb != nil {
+		for _, line := range strings.Split(strings.TrimRight(string(output), "\r\n"), "\n") {
+			if line != "" {
+				logCb(id, "reload", strings.TrimRight(line, "\r"), time.Now())
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("reload command failed: %w", err)
+	}
 	return nil
 }
 
 func (m *Manager) Stop(id string) error {
+	return m.stop(id, StatusStopped)
+}
+
+// stop stops the process and settles it in final, which is stopped or, for
+// a lazy process stopped for being idle, idle.
+func (m *Manager) stop(id string, final Status) error {
 	m.mu.Lock()
 	item, ok := m.entries[id]
 	if !ok {
@@ -176,45 +599,138 @@ func (m *Manager) Stop(id string) error {
 		return ErrNotFound
 	}
 
-	item.status = StatusStopped
+	item.status = final
+	item.stopStatus = final
 	item.manuallyStopped = true // Mark as manually stopped to prevent auto-restart
-	cmd := item.cmd
+	item.interrupt()
+	adoptedPID := item.adoptedPID
+	externalPID := item.externalPID
+	item.externalPID = 0
+	// A rolling restart may have a second instance in flight
+	instances := make([]*instance, 0, 3)
+	for _, inst := range []*instance{item.inst, item.standby, item.next} {
+		if inst != nil {
+			instances = append(instances, inst)
+		}
+	}
+	item.standby = nil
+	item.next = nil
 	m.mu.Unlock()
 
-	if cmd != nil && cmd.Process != nil {
-		// Try graceful stop first
-		done := make(chan struct{})
-		go func() {
-			gracefulStop(cmd, done)
-		}()
+	if adoptedPID != 0 {
+		return stopProcessGroup(adoptedPID)
+	}
+	if externalPID != 0 {
+		return stopPID(externalPID)
+	}
 
-		// Wait for graceful stop or timeout
-		select {
-		case <-done:
-			// Wait a bit for the process to exit gracefully
-			timer := time.NewTimer(GracefulStopTimeout)
-			defer timer.Stop()
-
-			for {
-				select {
-				case <-timer.C:
-					// Timeout, force kill
-					return killProcess(cmd)
-				default:
-					if !isProcessRunning(cmd.Process.Pid) {
-						return nil
-					}
-					time.Sleep(100 * time.Millisecond)
-				}
+	var err error
+	for _, inst := range instances {
+		if stopErr := stopCmd(inst.cmd); stopErr != nil {
+			if err == nil {
+				err = stopErr
 			}
-		case <-time.After(GracefulStopTimeout):
-			return killProcess(cmd)
+			continue
 		}
+		// Return only once the remaining output is logged, so that a
+		// shutdown closing the log stores right after loses no lines
+		<-inst.exited
 	}
-	return nil
+	return err
+}
+
+// RollingRestart replaces a running process without a gap: it starts a new
+// instance next to the old one, waits for its readiness check, then
+// gracefully stops the old one. If the new instance does not become ready it
+// is stopped and the old one keeps running. A TCP or HTTP check only passes
+// once the new instance listens on the probed port itself. The service must
+// tolerate two instances at once, e.g. via SO_REUSEPORT or sockets held by
+// ProcHub.
+func (m *Manager) RollingRestart(ctx context.Context, id string) error {
+	m.mu.Lock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	if item.status != StatusRunning || item.inst == nil {
+		m.mu.Unlock()
+		return ErrNotRunning
+	}
+	if item.standby != nil || item.next != nil {
+		m.mu.Unlock()
+		return ErrHandoverRunning
+	}
+	old := item.inst
+	def := item.definition
+	m.mu.Unlock()
+
+	def, inst, err := m.launch(ctx, id, def)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRolledBack, err)
+	}
+	m.mu.Lock()
+	switch {
+	case m.entries[id] != item || item.status != StatusRunning || item.inst != old:
+		err = ErrNotRunning
+	case item.standby != nil || item.next != nil:
+		err = ErrHandoverRunning
+	}
+	if err != nil {
+		// Stopped, restarted or handed over while the new instance started
+		m.mu.Unlock()
+		_ = stopCmd(inst.cmd)
+		return err
+	}
+	item.standby = inst
+	m.mu.Unlock()
+
+	err = waitReady(ctx, inst, def.Readiness, true)
+
+	m.mu.Lock()
+	if item.standby != inst {
+		// Stopped while the new instance was starting
+		m.mu.Unlock()
+		_ = stopCmd(inst.cmd)
+		return ErrNotRunning
+	}
+	item.standby = nil
+	if err == nil && item.inst != old {
+		err = errors.New("old instance exited during the handover")
+	}
+	if err == nil {
+		select {
+		case <-old.exited:
+			err = errors.New("old instance exited during the handover")
+		default:
+		}
+	}
+	if err != nil {
+		alertCb := m.alertCallback
+		m.mu.Unlock()
+		_ = stopCmd(inst.cmd)
+		err = fmt.Errorf("%w: %v", ErrRolledBack, err)
+		if alertCb != nil {
+			alertCb(id, err.Error())
+		}
+		return err
+	}
+	item.next = inst
+	m.mu.Unlock()
+
+	return stopCmd(old.cmd)
 }
 
 func (m *Manager) run(ctx context.Context, id string) {
+	m.mu.RLock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.RUnlock()
+		return
+	}
+	done := item.done
+	m.mu.RUnlock()
+
 	for {
 		m.mu.Lock()
 		item, ok := m.entries[id]
@@ -226,76 +742,178 @@ func (m *Manager) run(ctx context.Context, id string) {
 		// Clear previous error on restart
 		item.lastError = ""
 		item.manuallyStopped = false // Reset manual stop flag when starting
+		item.status = StatusStarting
+		item.inst = nil
+		def := item.definition
+		m.mu.Unlock()
 
-		cmd := exec.CommandContext(ctx, item.definition.Command, item.definition.Args...)
-		cmd.Dir = item.definition.WorkingDir
-		cmd.Env = append(cmd.Env, os.Environ()...)
-		cmd.Env = append(cmd.Env, envFromMap(item.definition.Env)...)
-
-		// Set up platform-specific process group for proper child process handling
-		setupProcessGroup(cmd)
-
-		// Capture stdout and stderr
-		stdout, _ := cmd.StdoutPipe()
-		stderr, _ := cmd.StderrPipe()
+		def, inst, err := m.launch(ctx, id, def)
 
-		item.cmd = cmd
+		m.mu.Lock()
+		if m.entries[id] != item || item.done != done || item.manuallyStopped {
+			// Removed, stopped or started anew while starting
+			m.mu.Unlock()
+			if inst != nil {
+				_ = stopCmd(inst.cmd)
+			}
+			return
+		}
+		item.inst = inst
 		item.status = StatusRunning
-		logCb := m.logCallback
+		if def.Readiness.Type != "" {
+			item.status = StatusStarting
+		}
+		item.stoppedAt = nil
+		if inst != nil {
+			startedAt := inst.startedAt
+			item.startedAt = &startedAt
+		}
 		m.mu.Unlock()
 
-		err := cmd.Start()
 		if err != nil {
 			m.recordError(id, err)
-			if !m.shouldRestart(id) {
+			if !m.shouldRestart(id, -1, false) {
+				return
+			}
+			if !m.checkCrashLoop(ctx, id, done, false) {
 				return
 			}
 			m.waitForRetry(id)
 			continue
 		}
 
-		// Stream stdout
-		if stdout != nil && logCb != nil {
-			go m.streamOutput(id, "stdout", stdout, logCb)
+		if def.Readiness.Type != "" {
+			go m.markReady(ctx, id, inst, def.Readiness)
 		}
-
-		// Stream stderr
-		if stderr != nil && logCb != nil {
-			go m.streamOutput(id, "stderr", stderr, logCb)
+		if def.Lazy {
+			go m.watchIdle(id, inst, def)
 		}
 
-		err = cmd.Wait()
-		if err != nil {
+		inst = m.waitInstance(id, inst)
+		err = inst.err
+		exitCode := getExitCode(inst.cmd)
+		succeeded := isSuccessExit(def, exitCode)
+		if !succeeded {
+			if err == nil {
+				err = fmt.Errorf("exit status %d", exitCode)
+			}
 			m.recordError(id, err)
 		}
 
 		m.mu.Lock()
+		stoppedAt := time.Now()
+		item.stoppedAt = &stoppedAt
+		item.duration = stoppedAt.Sub(inst.startedAt)
+		item.exitCode = &exitCode
 		// Check if manually stopped - don't auto-restart if user explicitly stopped
 		if item.manuallyStopped {
-			item.status = StatusStopped
+			item.status = item.stopStatus
+			m.mu.Unlock()
+			return
+		}
+		// A task that succeeded is done for good
+		if def.Kind == KindTask && succeeded {
+			item.status = StatusCompleted
 			m.mu.Unlock()
 			return
 		}
 		m.mu.Unlock()
 
-		if !m.shouldRestart(id) {
+		if !m.shouldRestart(id, exitCode, succeeded) {
+			return
+		}
+		if !m.checkCrashLoop(ctx, id, done, succeeded) {
 			return
 		}
 		m.waitForRetry(id)
 	}
 }
 
-func (m *Manager) streamOutput(id, stream string, reader io.Reader, callback LogCallback) {
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if callback != nil {
-			callback(id, stream, line)
+// launch resolves def and starts an instance of it. Only the lookup of
+// references holds m.mu, so allocating ports, binding sockets and starting
+// the command do not block other callers. The caller must not hold m.mu and
+// has to check whether the instance is still wanted before publishing it.
+func (m *Manager) launch(ctx context.Context, id string, def Definition) (Definition, *instance, error) {
+	if err := m.allocatePorts(def); err != nil {
+		return def, nil, err
+	}
+	m.mu.RLock()
+	def, err := m.resolve(def)
+	m.mu.RUnlock()
+	if err != nil {
+		return def, nil, err
+	}
+	inst, err := m.spawn(ctx, id, def)
+	return def, inst, err
+}
+
+// waitInstance waits for inst to exit. When a rolling restart has handed the
+// process over to a new instance meanwhile, it follows that one instead.
+func (m *Manager) waitInstance(id string, inst *instance) *instance {
+	for {
+		<-inst.exited
+
+		m.mu.Lock()
+		item, ok := m.entries[id]
+		if !ok || item.next == nil || item.manuallyStopped {
+			m.mu.Unlock()
+			return inst
+		}
+		inst = item.next
+		item.next = nil
+		item.inst = inst
+		startedAt := inst.startedAt
+		item.startedAt = &startedAt
+		m.mu.Unlock()
+	}
+}
+
+// markReady moves a started process from starting to running once its
+// readiness check passes. A failed check is recorded but the process is left
+// running.
+func (m *Manager) markReady(ctx context.Context, id string, inst *instance, check ReadinessCheck) {
+	err := waitReady(ctx, inst, check, false)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.entries[id]
+	if !ok || item.inst != inst || item.status != StatusStarting {
+		return
+	}
+	select {
+	case <-inst.exited:
+		return
+	default:
+	}
+	item.status = StatusRunning
+	if err != nil {
+		item.lastError = "readiness check failed: " + err.Error()
+	}
+}
+
+// streamOutput reads a stream of inst into its log queue, grouping
+// multi-line events when def asks for it
+func (m *Manager) streamOutput(reader io.Reader, stream string, inst *instance, def Definition) {
+	group := newLineGrouper(def.Multiline, func(line string, at time.Time) {
+		inst.logs.pushAt(stream, line, at)
+	})
+	readLines(reader, def.Output, func(line string) {
+		inst.observe(line)
+		if group != nil {
+			group.add(line)
+		} else {
+			inst.logs.push(stream, line)
 		}
+	})
+	if group != nil {
+		group.flush()
 	}
 }
 
-func (m *Manager) shouldRestart(id string) bool {
+// shouldRestart decides from the real exit status whether the process is
+// started again. Per-process exit code rules take precedence over the
+// restart policy; MaxRetries bounds restarts either way.
+func (m *Manager) shouldRestart(id string, exitCode int, succeeded bool) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -303,31 +921,108 @@ func (m *Manager) shouldRestart(id string) bool {
 	if !ok {
 		return false
 	}
+	def := item.definition
 
-	policy := item.definition.RestartPolicy
+	policy := def.RestartPolicy
 	if policy == "" {
 		policy = RestartOnFailure
 	}
 
-	if policy == RestartNever {
-		item.status = StatusStopped
-		return false
+	// Tasks only get here after a failed run, and a failed run of a task
+	// that will not be retried is final
+	failedStatus, stoppedStatus := StatusErrored, StatusStopped
+	if def.Kind == KindTask {
+		failedStatus, stoppedStatus = StatusFailed, StatusFailed
 	}
 
-	if policy == RestartOnFailure && item.lastError == "" {
-		item.status = StatusStopped
+	restart := false
+	switch {
+	case containsCode(def.RestartCodes, exitCode):
+		restart = true
+	case containsCode(def.NoRestartCodes, exitCode):
+		restart = false
+	case policy == RestartAlways:
+		restart = true
+	case policy == RestartOnFailure:
+		restart = !succeeded
+	}
+	if !restart {
+		item.status = stoppedStatus
 		return false
 	}
 
 	item.restarts++
-	if item.definition.MaxRetries > 0 && item.restarts > item.definition.MaxRetries {
-		item.status = StatusErrored
+	if def.MaxRetries > 0 && item.restarts > def.MaxRetries {
+		item.status = failedStatus
 		return false
 	}
 
 	return true
 }
 
+// checkCrashLoop feeds a run outcome to the crash-loop detector. When a loop
+// is detected the process moves to backoff and this waits for the cooldown,
+// or to fatal when it must wait for the user. It reports whether the run
+// loop should go on restarting.
+func (m *Manager) checkCrashLoop(ctx context.Context, id string, done chan struct{}, succeeded bool) bool {
+	if succeeded {
+		return true
+	}
+
+	m.mu.Lock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.Unlock()
+		return false
+	}
+	policy := item.definition.CrashLoop
+	if !item.crash.recordFailure(policy, time.Now()) {
+		m.mu.Unlock()
+		return true
+	}
+
+	maxFailures, window, cooldown := policy.limits()
+	item.drainWake()
+	message := fmt.Sprintf("crash loop detected: %d failures within %s", maxFailures, window)
+	if cooldown < 0 {
+		item.status = StatusFatal
+		message += ", waiting for the user to resume"
+	} else {
+		until := time.Now().Add(cooldown)
+		item.status = StatusBackoff
+		item.crash.backoffUntil = &until
+		message += fmt.Sprintf(", retrying in %s", cooldown)
+	}
+	item.lastError = message
+	alertCb := m.alertCallback
+	m.mu.Unlock()
+
+	if alertCb != nil {
+		alertCb(id, message)
+	}
+	if cooldown < 0 {
+		return false
+	}
+
+	timer := time.NewTimer(cooldown)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-item.wake:
+	case <-ctx.Done():
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item.crash.backoffUntil = nil
+	// Stopped, replaced by a newer run or shutting down while backing off
+	if item.manuallyStopped || item.done != done || ctx.Err() != nil {
+		return false
+	}
+	item.status = StatusStarting
+	return true
+}
+
 func (m *Manager) waitForRetry(id string) {
 	m.mu.RLock()
 	_, ok := m.entries[id]
@@ -351,6 +1046,155 @@ func (m *Manager) recordError(id string, err error) {
 	item.status = StatusErrored
 }
 
+// instances counts the live instances, which is two during a rolling restart
+func (e *entry) instances() int {
+	count := 0
+	for _, inst := range []*instance{e.inst, e.standby, e.next} {
+		if inst == nil {
+			continue
+		}
+		select {
+		case <-inst.exited:
+		default:
+			count++
+		}
+	}
+	return count
+}
+
+// isActive reports whether a run loop owns the process
+func (e *entry) isActive() bool {
+	return e.status == StatusRunning || e.status == StatusStarting || e.status == StatusBackoff
+}
+
+// watchingExternal reports whether an external process is being watched,
+// including while it is not found
+func (e *entry) watchingExternal() bool {
+	if e.definition.Kind != KindExternal || e.done == nil || e.manuallyStopped {
+		return false
+	}
+	select {
+	case <-e.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// interrupt wakes a run loop waiting out a crash-loop backoff
+func (e *entry) interrupt() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drainWake discards a wake-up left over from before the backoff started
+func (e *entry) drainWake() {
+	select {
+	case <-e.wake:
+	default:
+	}
+}
+
+// snapshot returns the public view of the entry. Callers must hold m.mu.
+func (e *entry) snapshot() Snapshot {
+	return Snapshot{
+		Definition:   e.definition,
+		PID:          e.pid(),
+		Status:       e.status,
+		Restarts:     e.restarts,
+		LastError:    e.lastError,
+		StartedAt:    e.startedAt,
+		StoppedAt:    e.stoppedAt,
+		ExitCode:     e.exitCode,
+		Duration:     e.duration.Milliseconds(),
+		CrashLoop:    e.crash.state(e.definition.CrashLoop),
+		Instances:    e.instances(),
+		Listening:    e.listening(),
+		Connections:  e.connections,
+		LastActivity: e.lastActivity,
+		DroppedLines: e.droppedLines.Load(),
+		LogBacklog:   e.logBacklog(),
+	}
+}
+
+// logBacklog returns the output lines of the live instances waiting for the
+// log callback
+func (e *entry) logBacklog() int {
+	backlog := 0
+	for _, inst := range []*instance{e.inst, e.standby, e.next} {
+		if inst != nil && inst.logs != nil {
+			backlog += inst.logs.backlog()
+		}
+	}
+	return backlog
+}
+
+// listening returns the bound socket addresses, if any
+func (e *entry) listening() []string {
+	if e.sockets == nil {
+		return nil
+	}
+	return e.sockets.addresses()
+}
+
+// pid returns the PID of the running instance, whether started by us or
+// adopted from a previous session.
+func (e *entry) pid() int {
+	if e.adoptedPID != 0 {
+		return e.adoptedPID
+	}
+	if e.externalPID != 0 {
+		return e.externalPID
+	}
+	if e.inst == nil {
+		return 0
+	}
+	return pidOf(e.inst.cmd)
+}
+
+// stopProcessGroup gracefully stops a process group we have no *exec.Cmd
+// for, force killing it after GracefulStopTimeout.
+func stopProcessGroup(pid int) error {
+	_ = terminateProcessGroup(pid)
+	deadline := time.Now().Add(GracefulStopTimeout)
+	for time.Now().Before(deadline) {
+		if !isProcessGroupAlive(pid) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return killProcessGroup(pid)
+}
+
+func orphanFromRecord(rec stateRecord) Orphan {
+	return Orphan{
+		ID:        rec.ID,
+		Name:      rec.Name,
+		PID:       rec.PID,
+		Command:   rec.Command,
+		StartedAt: rec.StartedAt,
+	}
+}
+
+// isSuccessExit reports whether code counts as a successful exit for def
+func isSuccessExit(def Definition, code int) bool {
+	if len(def.SuccessCodes) == 0 {
+		return code == 0
+	}
+	return containsCode(def.SuccessCodes, code)
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
 func pidOf(cmd *exec.Cmd) int {
 	if cmd == nil || cmd.Process == nil {
 		return 0
@@ -1,6 +1,20 @@
 package process
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestSetAutoStart(t *testing.T) {
 	m := NewManager()
@@ -32,3 +46,344 @@ func TestSetAutoStart(t *testing.T) {
 		t.Errorf("expected ErrNotFound for unknown process, got %v", err)
 	}
 }
+
+func TestShouldRestartExitCodes(t *testing.T) {
+	def := Definition{
+		ID:             "proc-1",
+		RestartPolicy:  RestartOnFailure,
+		SuccessCodes:   []int{0, 2},
+		NoRestartCodes: []int{3},
+		RestartCodes:   []int{75},
+	}
+	cases := []struct {
+		name     string
+		policy   RestartPolicy
+		exitCode int
+		want     bool
+	}{
+		{"success code", RestartOnFailure, 2, false},
+		{"failure", RestartOnFailure, 1, true},
+		{"never restart code", RestartOnFailure, 3, false},
+		{"never restart code beats always", RestartAlways, 3, false},
+		{"always restart code", RestartOnFailure, 75, true},
+		{"always restart code beats never", RestartNever, 75, true},
+		{"never policy", RestartNever, 1, false},
+	}
+	for _, tc := range cases {
+		m := NewManager()
+		d := def
+		d.RestartPolicy = tc.policy
+		m.Register(d)
+
+		got := m.shouldRestart("proc-1", tc.exitCode, isSuccessExit(d, tc.exitCode))
+		if got != tc.want {
+			t.Errorf("%s: shouldRestart(exit %d) = %v, want %v", tc.name, tc.exitCode, got, tc.want)
+		}
+	}
+}
+
+func TestCrashLoopDetector(t *testing.T) {
+	policy := CrashLoopPolicy{MaxFailures: 3, Window: 10}
+	var c crashLoop
+	now := time.Now()
+
+	if c.recordFailure(policy, now) || c.recordFailure(policy, now.Add(time.Second)) {
+		t.Fatal("tripped before reaching MaxFailures")
+	}
+	// The first two failures fall out of the window
+	if c.recordFailure(policy, now.Add(20*time.Second)) {
+		t.Fatal("tripped on failures outside the window")
+	}
+	c.recordFailure(policy, now.Add(21*time.Second))
+	if !c.recordFailure(policy, now.Add(22*time.Second)) {
+		t.Fatal("expected a trip after three failures within the window")
+	}
+	if c.trips != 1 || len(c.failures) != 0 {
+		t.Errorf("after trip: trips = %d, failures = %d, want 1 and 0", c.trips, len(c.failures))
+	}
+
+	disabled := CrashLoopPolicy{MaxFailures: -1}
+	for i := 0; i < 10; i++ {
+		if c.recordFailure(disabled, now) {
+			t.Fatal("tripped with detection disabled")
+		}
+	}
+}
+
+func TestPortAllocationAndReferences(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	newManager := func() *Manager {
+		m := NewManager()
+		m.SetPortFile(path)
+		m.SetPortRange(41000, 41999)
+		m.Register(Definition{ID: "proc-1", Name: "api", Ports: []string{"PORT", "PORT_ADMIN"}})
+		m.Register(Definition{
+			ID:   "proc-2",
+			Name: "web",
+			Env:  Environment{"API_URL": "http://127.0.0.1:${proc:api.PORT}"},
+		})
+		return m
+	}
+
+	m := newManager()
+	m.mu.Lock()
+	web, err := m.resolve(m.entries["proc-2"].definition)
+	api, _ := m.resolve(m.entries["proc-1"].definition)
+	m.mu.Unlock()
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if api.Env["PORT"] == "" || api.Env["PORT"] == api.Env["PORT_ADMIN"] {
+		t.Fatalf("expected two distinct ports, got %v", api.Env)
+	}
+	if want := "http://127.0.0.1:" + api.Env["PORT"]; web.Env["API_URL"] != want {
+		t.Errorf("API_URL = %q, want %q", web.Env["API_URL"], want)
+	}
+
+	// A new session gets the same ports back
+	snap, _ := newManager().Get("proc-1")
+	if strconv.Itoa(snap.Ports["PORT"]) != api.Env["PORT"] {
+		t.Errorf("ports changed across sessions: %v vs %v", snap.Ports, api.Env)
+	}
+
+	m.mu.Lock()
+	_, err = m.resolve(Definition{ID: "proc-3", Args: []string{"${proc:db.PORT}"}})
+	m.mu.Unlock()
+	if !errors.Is(err, ErrUnresolvedReference) {
+		t.Errorf("expected ErrUnresolvedReference, got %v", err)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "bad.env")
+	os.WriteFile(envFile, []byte("GOOD=1\nnot a pair\n"), 0o644)
+
+	m := NewManager()
+	m.SetPortRange(42000, 42999)
+	issues := m.Preflight(Definition{
+		ID:         "proc-1",
+		Command:    "prochub-no-such-command",
+		WorkingDir: filepath.Join(dir, "missing"),
+		EnvFiles:   []string{envFile},
+		Env:        Environment{"DB": "${proc:db.PORT}"},
+	})
+	fields := make(map[string]bool)
+	for _, issue := range issues {
+		fields[issue.Field] = true
+	}
+	for _, field := range []string{"command", "workingDir", "envFiles", "references"} {
+		if !fields[field] {
+			t.Errorf("expected an issue for %s, got %+v", field, issues)
+		}
+	}
+	if !errors.Is(PreflightError(issues), ErrPreflightFailed) {
+		t.Error("expected PreflightError to fail")
+	}
+
+	// A relaunched process would not have the pid it is found by
+	issues = m.Preflight(Definition{ID: "ext", Kind: KindExternal, External: ExternalMatch{PID: os.Getpid()}, Command: os.Args[0]})
+	if len(issues) == 0 || issues[0].Field != "external" {
+		t.Errorf("expected an external issue for a pid with a command, got %+v", issues)
+	}
+
+	// Checking a process must not allocate its ports, nor those it refers to
+	m.Register(Definition{ID: "db", Command: os.Args[0], Ports: []string{"PORT"}})
+	issues = m.Preflight(Definition{ID: "proc-3", Command: os.Args[0], Ports: []string{"PORT"}, Env: Environment{"DB": "${proc:db.PORT}"}})
+	if len(issues) != 1 || issues[0].Level != IssueWarning || issues[0].Field != "references" {
+		t.Errorf("expected a warning for the unallocated reference, got %+v", issues)
+	}
+	if ports := m.ports.peek("proc-3"); ports != nil {
+		t.Errorf("expected no ports allocated by preflight, got %v", ports)
+	}
+	if ports := m.ports.peek("db"); ports != nil {
+		t.Errorf("expected no ports allocated for references, got %v", ports)
+	}
+
+	def := Definition{ID: "proc-2", Command: os.Args[0], Ports: []string{"PORT"}}
+	m.Register(def)
+	if issues := m.Preflight(def); PreflightError(issues) != nil {
+		t.Fatalf("expected a clean preflight, got %+v", issues)
+	}
+	// Starting allocates the port, which something else then takes
+	ports, _ := m.ports.ports("proc-2", def.Ports)
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(ports["PORT"]))
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer l.Close()
+	issues = m.Preflight(def)
+	if len(issues) != 1 || issues[0].Field != "ports" {
+		t.Fatalf("expected a port conflict, got %+v", issues)
+	}
+	if runtime.GOOS == "linux" && issues[0].PID != os.Getpid() {
+		t.Errorf("expected the conflict to name pid %d, got %d", os.Getpid(), issues[0].PID)
+	}
+}
+
+func TestMatchProcessPrefersTopmostOldest(t *testing.T) {
+	now := time.Now()
+	procs := []procInfo{
+		{PID: 10, PPID: 1, CommandLine: "sh -c node server.js", StartedAt: now},
+		{PID: 11, PPID: 10, CommandLine: "node server.js", StartedAt: now},
+		{PID: 20, PPID: 1, CommandLine: "node server.js", StartedAt: now.Add(-time.Hour)},
+		{PID: 30, PPID: 1, CommandLine: "node other.js", StartedAt: now.Add(-2 * time.Hour)},
+	}
+	pattern := regexp.MustCompile(`node server\.js`)
+	if pid := matchProcess(procs, pattern, 0); pid != 20 {
+		t.Fatalf("expected the oldest topmost match 20, got %d", pid)
+	}
+	if pid := matchProcess(procs, pattern, 20); pid != 10 {
+		t.Fatalf("expected the shell 10 once 20 is excluded, got %d", pid)
+	}
+}
+
+func TestLogQueueOverflow(t *testing.T) {
+	cases := []struct {
+		policy OverflowPolicy
+		want   []string
+	}{
+		{OverflowDropOldest, []string{"0", "2", "3"}},
+		{OverflowDropNewest, []string{"0", "1", "2"}},
+		{OverflowBlock, []string{"0", "1", "2", "3"}},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			release := make(chan struct{})
+			delivering := make(chan struct{}, 1)
+			var got []string
+			var dropped atomic.Int64
+			q := newLogQueue(LogQueuePolicy{Size: 2, Overflow: tc.policy}, &dropped, func(stream, line string, at time.Time) {
+				delivering <- struct{}{}
+				<-release
+				got = append(got, line)
+			})
+
+			// The first line is taken and stalls the sink; the next two fill the queue
+			q.push("stdout", "0")
+			<-delivering
+			q.push("stdout", "1")
+			q.push("stdout", "2")
+			pushed := make(chan struct{})
+			go func() {
+				q.push("stdout", "3")
+				close(pushed)
+			}()
+			if tc.policy == OverflowBlock {
+				select {
+				case <-pushed:
+					t.Fatal("push did not block on a full queue")
+				case <-time.After(50 * time.Millisecond):
+				}
+			} else {
+				<-pushed
+			}
+
+			go func() {
+				for range delivering {
+				}
+			}()
+			close(release)
+			<-pushed
+			q.close()
+			<-q.done
+			close(delivering)
+
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("delivered %v, want %v", got, tc.want)
+			}
+			if want := int64(4 - len(tc.want)); dropped.Load() != want {
+				t.Fatalf("dropped %d, want %d", dropped.Load(), want)
+			}
+		})
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	long := strings.Repeat("a", 150)
+	cases := []struct {
+		name   string
+		input  string
+		policy OutputPolicy
+		want   []string
+	}{
+		{"partial final line", "one\ntwo", OutputPolicy{}, []string{"one", "two"}},
+		{"crlf", "one\r\ntwo\r\n", OutputPolicy{}, []string{"one", "two"}},
+		{"progress", "10%\r50%\r100%\ndone\n", OutputPolicy{}, []string{"100%", "done"}},
+		{"binary", "ok\x00\xff\tend\x1b[0m\n", OutputPolicy{}, []string{`ok\x00\xff` + "\tend\x1b[0m"}},
+		{"utf-8", "héllo wörld\n", OutputPolicy{}, []string{"héllo wörld"}},
+		{"split", long + "\nnext\n", OutputPolicy{MaxLineBytes: 100}, []string{
+			long[:100-len(continuedMarker)] + continuedMarker, long[100-len(continuedMarker):], "next",
+		}},
+		{"truncate", long + "\nnext\n", OutputPolicy{MaxLineBytes: 100, LongLines: LongLinesTruncate}, []string{
+			long[:100] + " [... 50 bytes truncated]", "next",
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			readLines(strings.NewReader(tc.input), tc.policy, func(line string) {
+				got = append(got, line)
+			})
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tc.want) {
+				t.Fatalf("lines = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// Lines far beyond the read buffer and the old scanner limit keep the
+	// output flowing
+	huge := strings.Repeat("x", 1<<20)
+	var pieces, total int
+	var last string
+	readLines(strings.NewReader(huge+"\nafter\n"), OutputPolicy{}, func(line string) {
+		pieces++
+		total += len(strings.TrimSuffix(line, continuedMarker))
+		last = line
+	})
+	if total != len(huge)+len("after") || last != "after" || pieces < 16 {
+		t.Fatalf("split a 1MB line into %d pieces of %d bytes in total, last %q", pieces, total, last)
+	}
+}
+
+func TestLineGrouper(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	g := newLineGrouper(MultilinePolicy{Continuation: `^Caused by:`, Indented: true, MaxLines: 4, Timeout: 50}, func(line string, at time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, line)
+	})
+	for _, line := range []string{
+		"starting",
+		"java.lang.IllegalStateException: boom",
+		"\tat App.run(App.java:10)",
+		"Caused by: java.io.IOException: disk",
+		"\tat Disk.read(Disk.java:3)",
+		"\t... 5 more",
+		"next",
+	} {
+		g.add(line)
+	}
+	// The last event is emitted once the timeout passes
+	time.Sleep(200 * time.Millisecond)
+	g.add("  orphan indented line")
+	g.flush()
+
+	want := []string{
+		"starting",
+		"java.lang.IllegalStateException: boom\n\tat App.run(App.java:10)\nCaused by: java.io.IOException: disk\n\tat Disk.read(Disk.java:3)",
+		"\t... 5 more",
+		"next",
+		"  orphan indented line",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprintf("%q", events) != fmt.Sprintf("%q", want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+
+	if newLineGrouper(MultilinePolicy{MaxLines: 10}, nil) != nil {
+		t.Error("expected grouping to be off without a continuation rule")
+	}
+}
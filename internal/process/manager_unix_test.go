@@ -0,0 +1,483 @@
+//go:build !windows
+
+package process
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitStatus polls until the process reaches want or fails the test.
+func waitStatus(t *testing.T, m *Manager, id string, want Status) Snapshot {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snap, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if snap.Status == want {
+			return snap
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %q, want %q", snap.Status, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func waitGroupExit(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for isProcessGroupAlive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("process group %d is still alive", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTaskCompleted(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{ID: "task-1", Kind: KindTask, Command: "true"})
+	if err := m.RunNow(context.Background(), "task-1"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+
+	snap := waitStatus(t, m, "task-1", StatusCompleted)
+	if snap.ExitCode == nil || *snap.ExitCode != 0 {
+		t.Errorf("expected exit code 0, got %v", snap.ExitCode)
+	}
+	if snap.StartedAt == nil || snap.StoppedAt == nil {
+		t.Error("expected start and stop times to be recorded")
+	}
+}
+
+func TestTaskFailed(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{ID: "task-1", Kind: KindTask, Command: "exit 3", ShellMode: true, RestartPolicy: RestartNever})
+	if err := m.RunNow(context.Background(), "task-1"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+
+	snap := waitStatus(t, m, "task-1", StatusFailed)
+	if snap.ExitCode == nil || *snap.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %v", snap.ExitCode)
+	}
+}
+
+func TestTaskSuccessCodes(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{ID: "task-1", Kind: KindTask, Command: "exit 2", ShellMode: true, SuccessCodes: []int{0, 2}})
+	if err := m.RunNow(context.Background(), "task-1"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+
+	snap := waitStatus(t, m, "task-1", StatusCompleted)
+	if snap.LastError != "" {
+		t.Errorf("expected no error for a success code, got %q", snap.LastError)
+	}
+}
+
+func TestRunNowRefusesSecondCopy(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{ID: "task-1", Kind: KindTask, Command: "sleep", Args: []string{"30"}})
+	if err := m.RunNow(context.Background(), "task-1"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	defer m.Stop("task-1")
+
+	waitStatus(t, m, "task-1", StatusRunning)
+	if err := m.RunNow(context.Background(), "task-1"); err != ErrAlreadyRunning {
+		t.Errorf("expected ErrAlreadyRunning, got %v", err)
+	}
+}
+
+func TestCrashLoopFatal(t *testing.T) {
+	alerts := make(chan string, 1)
+	m := NewManager()
+	m.SetAlertCallback(func(processID, message string) {
+		alerts <- processID
+	})
+	m.Register(Definition{
+		ID:            "proc-1",
+		Command:       "false",
+		RestartPolicy: RestartAlways,
+		CrashLoop:     CrashLoopPolicy{MaxFailures: 2, Window: 60, Cooldown: -1},
+	})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	snap := waitStatus(t, m, "proc-1", StatusFatal)
+	if snap.CrashLoop.Trips != 1 {
+		t.Errorf("expected one crash-loop trip, got %d", snap.CrashLoop.Trips)
+	}
+	select {
+	case id := <-alerts:
+		if id != "proc-1" {
+			t.Errorf("alert for %q, want proc-1", id)
+		}
+	default:
+		t.Error("expected an alert when the crash loop was detected")
+	}
+
+	if err := m.Resume(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	snap, _ = m.Get("proc-1")
+	if snap.Status == StatusFatal {
+		t.Error("expected Resume to restart a fatal process")
+	}
+	m.Stop("proc-1")
+}
+
+// logRecorder collects log lines per stream from a Manager
+type logRecorder struct {
+	mu    sync.Mutex
+	lines map[string][]string
+}
+
+func recordLogs(m *Manager) *logRecorder {
+	r := &logRecorder{lines: make(map[string][]string)}
+	m.SetLogCallback(func(processID, stream, line string, at time.Time) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lines[stream] = append(r.lines[stream], line)
+	})
+	return r
+}
+
+// waitLine polls until line shows up on stream or fails the test.
+func (r *logRecorder) waitLine(t *testing.T, stream, line string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		for _, got := range r.lines[stream] {
+			if got == line {
+				r.mu.Unlock()
+				return
+			}
+		}
+		r.mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("line %q not seen on %s", line, stream)
+}
+
+func TestStopAllWaitsForOutput(t *testing.T) {
+	m := NewManager()
+	logs := recordLogs(m)
+	m.Register(Definition{ID: "proc-1", Command: "trap 'echo bye; exit 0' TERM; echo ready; while :; do sleep 0.1; done", ShellMode: true})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	logs.waitLine(t, "stdout", "ready")
+
+	m.StopAll()
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	if got := logs.lines["stdout"]; len(got) == 0 || got[len(got)-1] != "bye" {
+		t.Errorf("expected the last line before StopAll returned, got %q", got)
+	}
+}
+
+func TestSignalProcess(t *testing.T) {
+	m := NewManager()
+	logs := recordLogs(m)
+	m.Register(Definition{
+		ID:        "proc-1",
+		Command:   "trap 'echo got hup' HUP; echo ready; while true; do sleep 0.1; done",
+		ShellMode: true,
+	})
+	if err := m.Signal("proc-1", "HUP", false); err != ErrNotRunning {
+		t.Errorf("expected ErrNotRunning before start, got %v", err)
+	}
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop("proc-1")
+
+	logs.waitLine(t, "stdout", "ready")
+	if err := m.Signal("proc-1", "SIGHUP", false); err != nil {
+		t.Fatalf("Signal failed: %v", err)
+	}
+	logs.waitLine(t, "stdout", "got hup")
+
+	if err := m.Signal("proc-1", "NOPE", false); err != ErrUnsupportedSignal {
+		t.Errorf("expected ErrUnsupportedSignal, got %v", err)
+	}
+}
+
+func TestReloadCommand(t *testing.T) {
+	m := NewManager()
+	logs := recordLogs(m)
+	m.Register(Definition{
+		ID:      "proc-1",
+		Command: "sleep",
+		Args:    []string{"30"},
+		Env:     Environment{"GREETING": "reloaded"},
+		Reload:  ReloadAction{Command: "echo $GREETING"},
+	})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop("proc-1")
+	waitStatus(t, m, "proc-1", StatusRunning)
+
+	if err := m.Reload(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	logs.waitLine(t, "reload", "reloaded")
+}
+
+func TestRollingRestart(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{
+		ID:        "proc-1",
+		Command:   "echo ready; sleep 30",
+		ShellMode: true,
+		Readiness: ReadinessCheck{Type: ReadyLog, Target: "^ready$"},
+	})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop("proc-1")
+	old := waitStatus(t, m, "proc-1", StatusRunning)
+
+	if err := m.RollingRestart(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("RollingRestart failed: %v", err)
+	}
+	waitGroupExit(t, old.PID)
+
+	snap, _ := m.Get("proc-1")
+	if snap.Status != StatusRunning || snap.PID == old.PID || snap.PID == 0 {
+		t.Errorf("expected a new running instance, got status %q pid %d (old pid %d)", snap.Status, snap.PID, old.PID)
+	}
+	if snap.Restarts != 0 {
+		t.Errorf("a rolling restart should not count as a crash restart, got %d restarts", snap.Restarts)
+	}
+}
+
+func TestRollingRestartRollback(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{
+		ID:        "proc-1",
+		Command:   "echo ready; sleep 30",
+		ShellMode: true,
+		Readiness: ReadinessCheck{Type: ReadyLog, Target: "^ready$", Timeout: 1},
+	})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop("proc-1")
+	old := waitStatus(t, m, "proc-1", StatusRunning)
+
+	// The new instance never prints the readiness line
+	m.mu.Lock()
+	m.entries["proc-1"].definition.Command = "sleep 30"
+	m.mu.Unlock()
+
+	err := m.RollingRestart(context.Background(), "proc-1")
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("expected ErrRolledBack, got %v", err)
+	}
+	snap, _ := m.Get("proc-1")
+	if snap.PID != old.PID || !isProcessGroupAlive(old.PID) {
+		t.Errorf("expected the old instance %d to keep running, got pid %d", old.PID, snap.PID)
+	}
+	if snap.Instances != 1 {
+		t.Errorf("expected one live instance after rollback, got %d", snap.Instances)
+	}
+}
+
+func TestRollingRestartProbesNewInstance(t *testing.T) {
+	// The listener stands in for the old instance answering the probe
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	m := NewManager()
+	m.Register(Definition{
+		ID:        "proc-1",
+		Command:   "sleep 30",
+		ShellMode: true,
+		Readiness: ReadinessCheck{Type: ReadyTCP, Target: l.Addr().String(), Timeout: 1},
+	})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer m.Stop("proc-1")
+	old := waitStatus(t, m, "proc-1", StatusRunning)
+
+	// The new instance never listens, so the probe only reaches the old one
+	err = m.RollingRestart(context.Background(), "proc-1")
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("expected ErrRolledBack, got %v", err)
+	}
+	snap, _ := m.Get("proc-1")
+	if snap.PID != old.PID || !isProcessGroupAlive(old.PID) {
+		t.Errorf("expected the old instance %d to keep running, got pid %d", old.PID, snap.PID)
+	}
+}
+
+func TestSocketActivationEnv(t *testing.T) {
+	m := NewManager()
+	logs := recordLogs(m)
+	m.Register(Definition{
+		ID:            "proc-1",
+		Command:       `[ "$LISTEN_PID" = "$$" ] && echo "$LISTEN_FDS $LISTEN_FDNAMES" && [ -e /dev/fd/3 ] && echo fd3`,
+		ShellMode:     true,
+		RestartPolicy: RestartNever,
+		Sockets:       []Socket{{Name: "web", Address: "127.0.0.1:0"}},
+	})
+	defer m.Unregister("proc-1")
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	logs.waitLine(t, "stdout", "1 web")
+	logs.waitLine(t, "stdout", "fd3")
+
+	snap, _ := m.Get("proc-1")
+	if len(snap.Listening) != 1 || strings.HasSuffix(snap.Listening[0], ":0") {
+		t.Errorf("expected the bound address, got %v", snap.Listening)
+	}
+}
+
+func TestSocketOnDemand(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{
+		ID:       "proc-1",
+		Command:  "sleep",
+		Args:     []string{"30"},
+		Sockets:  []Socket{{Address: "127.0.0.1:0"}},
+		OnDemand: true,
+	})
+	defer m.Unregister("proc-1")
+	if err := m.ListenSockets(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("ListenSockets failed: %v", err)
+	}
+
+	snap, _ := m.Get("proc-1")
+	if snap.Status != StatusStopped || len(snap.Listening) != 1 {
+		t.Fatalf("expected a stopped process with a bound socket, got %q %v", snap.Status, snap.Listening)
+	}
+	conn, err := net.Dial("tcp", snap.Listening[0])
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	waitStatus(t, m, "proc-1", StatusRunning)
+}
+
+func TestLazyStartAndIdleStop(t *testing.T) {
+	m := NewManager()
+	m.idleCheck = 50 * time.Millisecond
+	m.Register(Definition{
+		ID:          "proc-1",
+		Command:     "sleep",
+		Args:        []string{"30"},
+		Lazy:        true,
+		IdleTimeout: 1,
+	})
+	defer m.Stop("proc-1")
+	if snap, _ := m.Get("proc-1"); snap.Status != StatusIdle {
+		t.Fatalf("expected a lazy process to start idle, got %q", snap.Status)
+	}
+
+	release, err := m.Acquire(context.Background(), "proc-1")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	snap, _ := m.Get("proc-1")
+	if snap.Status != StatusRunning || snap.Connections != 1 {
+		t.Fatalf("expected a running process with one connection, got %q with %d", snap.Status, snap.Connections)
+	}
+
+	// Held connections keep it running past the idle timeout
+	time.Sleep(1200 * time.Millisecond)
+	if snap, _ := m.Get("proc-1"); snap.Status != StatusRunning {
+		t.Fatalf("stopped while a connection was open, status %q", snap.Status)
+	}
+	release()
+	waitStatus(t, m, "proc-1", StatusIdle)
+	waitGroupExit(t, snap.PID)
+}
+
+func TestExternalProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "37")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start the external process: %v", err)
+	}
+	go cmd.Wait()
+
+	m := NewManager()
+	m.externalPoll = 50 * time.Millisecond
+	m.Register(Definition{
+		ID:            "proc-1",
+		Kind:          KindExternal,
+		External:      ExternalMatch{Pattern: "^sleep 37$"},
+		Command:       "sleep",
+		Args:          []string{"37"},
+		RestartPolicy: RestartAlways,
+	})
+	if err := m.Watch(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	m.mu.Lock()
+	done := m.entries["proc-1"].done
+	m.mu.Unlock()
+	snap := waitStatus(t, m, "proc-1", StatusRunning)
+	if snap.PID != cmd.Process.Pid {
+		t.Fatalf("expected pid %d, got %d", cmd.Process.Pid, snap.PID)
+	}
+	if stats, err := m.Stats("proc-1"); err != nil || stats.PID != snap.PID {
+		t.Fatalf("Stats = %+v, %v", stats, err)
+	}
+	if err := m.Signal("proc-1", "TERM", true); err != ErrExternalGroup {
+		t.Fatalf("expected ErrExternalGroup for a group signal, got %v", err)
+	}
+
+	// Losing it runs the restart command
+	cmd.Process.Kill()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snap, _ = m.Get("proc-1")
+		if snap.Status == StatusRunning && snap.PID != cmd.Process.Pid {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("external process was not relaunched, status %q pid %d", snap.Status, snap.PID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := m.Stop("proc-1"); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for isProcessRunning(snap.PID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("relaunched process %d is still running", snap.PID)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if snap, _ := m.Get("proc-1"); snap.Status != StatusStopped {
+		t.Fatalf("expected stopped, got %q", snap.Status)
+	}
+	// The watch ends with the stop
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch of the external process did not end")
+	}
+}
@@ -0,0 +1,119 @@
+package process
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMultilineMaxLines = 200
+	defaultMultilineTimeout  = 300 * time.Millisecond
+)
+
+// MultilinePolicy groups the lines of a multi-line event, such as a stack
+// trace, into one log line joined by newlines. Grouping is off unless
+// Continuation or Indented is set.
+type MultilinePolicy struct {
+	Continuation string `json:"continuation"` // Regex matching lines that belong to the line before, e.g. ^(Caused by:|\s)
+	Indented     bool   `json:"indented"`     // Lines starting with a space or tab belong to the line before
+	MaxLines     int    `json:"maxLines"`     // Lines per event, defaults to 200
+	Timeout      int    `json:"timeout"`      // milliseconds to wait for more lines, defaults to 300
+}
+
+// lineGrouper joins continuation lines to the line they follow. An event is
+// emitted once a line starts the next one, it reaches the line limit or no
+// line came within the timeout.
+type lineGrouper struct {
+	continuation *regexp.Regexp
+	indented     bool
+	maxLines     int
+	timeout      time.Duration
+	emit         func(line string, at time.Time)
+
+	mu    sync.Mutex
+	lines []string
+	at    time.Time // when the first line was read
+	timer *time.Timer
+	event int // counts events, so a late timer leaves the next one alone
+}
+
+// newLineGrouper returns a grouper for policy, or nil when grouping is off.
+// An invalid continuation pattern is reported by Preflight and ignored.
+func newLineGrouper(policy MultilinePolicy, emit func(line string, at time.Time)) *lineGrouper {
+	var continuation *regexp.Regexp
+	if policy.Continuation != "" {
+		continuation, _ = regexp.Compile(policy.Continuation)
+	}
+	if continuation == nil && !policy.Indented {
+		return nil
+	}
+	g := &lineGrouper{
+		continuation: continuation,
+		indented:     policy.Indented,
+		maxLines:     policy.MaxLines,
+		timeout:      time.Duration(policy.Timeout) * time.Millisecond,
+		emit:         emit,
+	}
+	if g.maxLines <= 0 {
+		g.maxLines = defaultMultilineMaxLines
+	}
+	if g.timeout <= 0 {
+		g.timeout = defaultMultilineTimeout
+	}
+	return g
+}
+
+// add takes the next line of the stream
+func (g *lineGrouper) add(line string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.lines) > 0 && len(g.lines) < g.maxLines && g.continues(line) {
+		g.lines = append(g.lines, line)
+		g.timer.Reset(g.timeout)
+		return
+	}
+	g.flushLocked()
+	g.lines = append(g.lines, line)
+	g.at = time.Now()
+	g.event++
+	event := g.event
+	g.timer = time.AfterFunc(g.timeout, func() { g.expire(event) })
+}
+
+// flush emits the pending event, if any
+func (g *lineGrouper) flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flushLocked()
+}
+
+// expire emits an event no line was added to within the timeout
+func (g *lineGrouper) expire(event int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.event == event {
+		g.flushLocked()
+	}
+}
+
+func (g *lineGrouper) flushLocked() {
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	if len(g.lines) == 0 {
+		return
+	}
+	g.emit(strings.Join(g.lines, "\n"), g.at)
+	g.lines = g.lines[:0]
+}
+
+func (g *lineGrouper) continues(line string) bool {
+	if g.indented && line != "" && (line[0] == ' ' || line[0] == '\t') {
+		return true
+	}
+	return g.continuation != nil && g.continuation.MatchString(line)
+}
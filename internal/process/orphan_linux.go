@@ -0,0 +1,83 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// setParentDeathSignal asks the kernel to kill the child when ProcHub dies,
+// so a crashed session does not leave unmanaged processes behind. The signal
+// actually fires when the thread that forked the child exits (golang/go#27505),
+// so the child must be started with startCmd.
+func setParentDeathSignal(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Pdeathsig = syscall.SIGKILL
+}
+
+// forker starts children with a parent death signal on one OS thread that
+// lives as long as ProcHub. Other threads may be retired by the runtime,
+// which would kill the children they forked.
+var forker struct {
+	once     sync.Once
+	requests chan forkRequest
+}
+
+type forkRequest struct {
+	cmd  *exec.Cmd
+	done chan error
+}
+
+// startCmd starts cmd, on the forker thread when it has a parent death
+// signal
+func startCmd(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil || cmd.SysProcAttr.Pdeathsig == 0 {
+		return cmd.Start()
+	}
+	forker.once.Do(func() {
+		forker.requests = make(chan forkRequest)
+		go func() {
+			// Never unlocked, so the thread is never handed back
+			runtime.LockOSThread()
+			for req := range forker.requests {
+				req.done <- req.cmd.Start()
+			}
+		}()
+	})
+	done := make(chan error, 1)
+	forker.requests <- forkRequest{cmd: cmd, done: done}
+	return <-done
+}
+
+// processStartTicks returns the start time of pid in clock ticks since boot,
+// or 0 when it cannot be read.
+func processStartTicks(pid int) uint64 {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0
+	}
+	// The command name may contain spaces, so split after its closing paren.
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0
+	}
+	fields := strings.Fields(stat[end+1:])
+	// starttime is field 22 overall, the 20th after "pid (comm)".
+	if len(fields) < 20 {
+		return 0
+	}
+	ticks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return ticks
+}
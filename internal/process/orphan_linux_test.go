@@ -0,0 +1,103 @@
+//go:build linux
+
+package process
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startStaleGroup starts a process in its own group and records it in a state
+// file as if a previous session had crashed while it was running.
+func startStaleGroup(t *testing.T, id string) (string, *exec.Cmd) {
+	t.Helper()
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start sleep failed: %v", err)
+	}
+	go cmd.Wait()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	state := newStateFile(path)
+	state.put(stateRecord{
+		ID:         id,
+		PID:        cmd.Process.Pid,
+		Command:    "sleep",
+		StartedAt:  time.Now(),
+		StartTicks: processStartTicks(cmd.Process.Pid),
+	})
+	return path, cmd
+}
+
+func TestSweepOrphansKill(t *testing.T) {
+	path, cmd := startStaleGroup(t, "proc-1")
+
+	m := NewManager()
+	m.SetStateFile(path)
+	m.Register(Definition{ID: "proc-1", Command: "sleep"})
+
+	found, err := m.SweepOrphans(OrphanKill)
+	if err != nil || len(found) != 1 || found[0].PID != cmd.Process.Pid {
+		t.Fatalf("SweepOrphans() = %+v, want the stale group", found)
+	}
+	waitGroupExit(t, cmd.Process.Pid)
+	if orphans := m.Orphans(); len(orphans) != 0 {
+		t.Errorf("expected no pending orphans after kill, got %+v", orphans)
+	}
+}
+
+func TestSweepOrphansKeep(t *testing.T) {
+	path, cmd := startStaleGroup(t, "proc-1")
+
+	m := NewManager()
+	m.SetStateFile(path)
+	m.Register(Definition{ID: "proc-1", Command: "sleep"})
+
+	if _, err := m.SweepOrphans("keeep"); !errors.Is(err, ErrUnknownOrphanPolicy) {
+		t.Fatalf("expected ErrUnknownOrphanPolicy, got %v", err)
+	}
+	if orphans := m.Orphans(); len(orphans) != 0 {
+		t.Fatalf("expected an unknown policy to leave orphans alone, got %+v", orphans)
+	}
+	m.SweepOrphans(OrphanKeep)
+	if orphans := m.Orphans(); len(orphans) != 1 {
+		t.Fatalf("expected one pending orphan, got %+v", orphans)
+	}
+	if err := m.Start(context.Background(), "proc-1"); err != ErrOrphanRunning {
+		t.Errorf("expected ErrOrphanRunning while orphan is alive, got %v", err)
+	}
+
+	if err := m.KillOrphan("proc-1"); err != nil {
+		t.Fatalf("KillOrphan failed: %v", err)
+	}
+	waitGroupExit(t, cmd.Process.Pid)
+}
+
+func TestSweepOrphansAdopt(t *testing.T) {
+	path, cmd := startStaleGroup(t, "proc-1")
+
+	m := NewManager()
+	m.SetStateFile(path)
+	m.Register(Definition{ID: "proc-1", Command: "sleep"})
+
+	m.SweepOrphans(OrphanAdopt)
+	snap, err := m.Get("proc-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if snap.Status != StatusRunning || snap.PID != cmd.Process.Pid {
+		t.Fatalf("expected adopted process to be running with pid %d, got %+v", cmd.Process.Pid, snap)
+	}
+
+	if err := m.Stop("proc-1"); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	waitGroupExit(t, cmd.Process.Pid)
+}
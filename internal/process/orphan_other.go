@@ -0,0 +1,19 @@
+//go:build !linux
+
+package process
+
+import "os/exec"
+
+// setParentDeathSignal is a no-op outside Linux; leftover processes are
+// found by the startup sweep instead.
+func setParentDeathSignal(cmd *exec.Cmd) {}
+
+// startCmd starts cmd
+func startCmd(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+// processStartTicks is not available outside Linux.
+func processStartTicks(pid int) uint64 {
+	return 0
+}
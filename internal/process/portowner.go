@@ -0,0 +1,36 @@
+package process
+
+// portOwner returns the PID of a process listening on the TCP port, or 0
+// when it cannot be found
+func portOwner(port int) int {
+	if pids := portOwners(port); len(pids) > 0 {
+		return pids[0]
+	}
+	return 0
+}
+
+// ownedBy reports whether pid or one of its descendants is among owners,
+// the listeners of a port. known is false when owners is empty, as no
+// listener can be seen at all, e.g. because it belongs to another user.
+func ownedBy(owners []int, pid int) (owned, known bool) {
+	if len(owners) == 0 {
+		return false, false
+	}
+	procs, err := listProcesses()
+	if err != nil {
+		return false, false
+	}
+	parents := make(map[int]int, len(procs))
+	for _, p := range procs {
+		parents[p.PID] = p.PPID
+	}
+	for _, owner := range owners {
+		// Bounded, in case of a cycle from a PID reused while listing
+		for p, hops := owner, 0; p > 1 && hops < 64; p, hops = parents[p], hops+1 {
+			if p == pid {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
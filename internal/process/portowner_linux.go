@@ -0,0 +1,152 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TCP states in /proc/net/tcp
+const (
+	tcpEstablished = "01"
+	tcpListen      = "0A"
+)
+
+// scanTCP calls fn with the local port, state and socket inode of every
+// entry in the kernel's TCP tables. It reports whether any table was read.
+func scanTCP(fn func(port int, state, inode string)) bool {
+	read := false
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, err := os.ReadFile(table)
+		if err != nil {
+			continue
+		}
+		read = true
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			// local_address is field 1 (hex ip:port), st is field 3, inode field 9
+			if len(fields) < 10 {
+				continue
+			}
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			if port, err := strconv.ParseInt(hexPort, 16, 32); err == nil {
+				fn(int(port), fields[3], fields[9])
+			}
+		}
+	}
+	return read
+}
+
+// portOwners returns the PIDs of the processes listening on the TCP port.
+// Processes sharing a listener are all returned; those of other users are
+// not found.
+func portOwners(port int) []int {
+	inodes := make(map[string]bool)
+	scanTCP(func(p int, state, inode string) {
+		if p == port && state == tcpListen {
+			inodes["socket:["+inode+"]"] = true
+		}
+	})
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	var pids []int
+	seen := make(map[int]bool)
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err != nil || !inodes[target] {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.Split(fd, "/")[2])
+		if err == nil && !seen[pid] {
+			seen[pid] = true
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// listensOn reports whether pid or one of its descendants listens on the
+// TCP port. Only the descriptors of those processes are looked at, so it is
+// cheap enough to poll. known is false when the TCP tables cannot be read.
+func listensOn(pid, port int) (listens, known bool) {
+	inodes := make(map[string]bool)
+	read := scanTCP(func(p int, state, inode string) {
+		if p == port && state == tcpListen {
+			inodes["socket:["+inode+"]"] = true
+		}
+	})
+	if !read {
+		return false, false
+	}
+	if len(inodes) == 0 {
+		return false, true
+	}
+	for _, p := range descendants(pid) {
+		fds, _ := filepath.Glob("/proc/" + strconv.Itoa(p) + "/fd/*")
+		for _, fd := range fds {
+			if target, err := os.Readlink(fd); err == nil && inodes[target] {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
+
+// descendants returns pid followed by the processes below it, from the
+// children lists of /proc, or from every process's parent on kernels
+// without them
+func descendants(pid int) []int {
+	root := strconv.Itoa(pid)
+	if _, err := os.Stat("/proc/" + root + "/task/" + root + "/children"); err != nil {
+		procs, err := listProcesses()
+		if err != nil {
+			return []int{pid}
+		}
+		children := make(map[int][]int)
+		for _, p := range procs {
+			children[p.PPID] = append(children[p.PPID], p.PID)
+		}
+		pids := []int{pid}
+		for i := 0; i < len(pids) && i < 1024; i++ {
+			pids = append(pids, children[pids[i]]...)
+		}
+		return pids
+	}
+	pids := []int{pid}
+	for i := 0; i < len(pids) && i < 1024; i++ {
+		files, _ := filepath.Glob("/proc/" + strconv.Itoa(pids[i]) + "/task/*/children")
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			for _, field := range strings.Fields(string(data)) {
+				if child, err := strconv.Atoi(field); err == nil {
+					pids = append(pids, child)
+				}
+			}
+		}
+	}
+	return pids
+}
+
+// connectionCount returns the number of established connections accepted
+// on the local TCP port, and false when they cannot be counted
+func connectionCount(port int) (int, bool) {
+	count := 0
+	ok := scanTCP(func(p int, state, inode string) {
+		if p == port && state == tcpEstablished {
+			count++
+		}
+	})
+	return count, ok
+}
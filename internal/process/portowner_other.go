@@ -0,0 +1,55 @@
+//go:build !linux && !windows
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// portOwners returns the PIDs of the processes listening on the TCP port
+func portOwners(port int) []int {
+	out, err := exec.Command("lsof", "-nP", "-t", "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN").Output()
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, field := range strings.Fields(string(out)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// listensOn reports whether pid or one of its descendants listens on the
+// TCP port. known is false when no listener can be seen.
+func listensOn(pid, port int) (listens, known bool) {
+	return ownedBy(portOwners(port), pid)
+}
+
+// connectionCount returns the number of established connections accepted
+// on the local TCP port, and false when they cannot be counted, e.g.
+// without lsof
+func connectionCount(port int) (int, bool) {
+	out, err := exec.Command("lsof", "-nP", "-iTCP:"+strconv.Itoa(port), "-sTCP:ESTABLISHED").Output()
+	if err != nil {
+		// lsof exits with 1 when it finds nothing
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(out) == 0 {
+			return 0, true
+		}
+		return 0, false
+	}
+	count := 0
+	suffix := ":" + strconv.Itoa(port) + "->"
+	for _, line := range strings.Split(string(out), "\n") {
+		// Only the accepting side has the port on the left of the arrow
+		if strings.Contains(line, suffix) {
+			count++
+		}
+	}
+	return count, true
+}
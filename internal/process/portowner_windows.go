@@ -0,0 +1,68 @@
+//go:build windows
+
+package process
+
+import (
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// netstat returns the TCP table rows as Proto, Local Address, Foreign
+// Address, State and PID fields
+func netstat() ([][]string, error) {
+	cmd := exec.Command("netstat", "-ano", "-p", "TCP")
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]string, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 5 {
+			rows = append(rows, fields)
+		}
+	}
+	return rows, nil
+}
+
+// portOwners returns the PIDs of the processes listening on the TCP port
+func portOwners(port int) []int {
+	suffix := ":" + strconv.Itoa(port)
+	rows, _ := netstat()
+	var pids []int
+	for _, fields := range rows {
+		if fields[3] != "LISTENING" || !strings.HasSuffix(fields[1], suffix) {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[4]); err == nil && !slices.Contains(pids, pid) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// listensOn reports whether pid or one of its descendants listens on the
+// TCP port. known is false when no listener can be seen.
+func listensOn(pid, port int) (listens, known bool) {
+	return ownedBy(portOwners(port), pid)
+}
+
+// connectionCount returns the number of established connections accepted
+// on the local TCP port, and false when they cannot be counted
+func connectionCount(port int) (int, bool) {
+	rows, err := netstat()
+	if err != nil {
+		return 0, false
+	}
+	suffix := ":" + strconv.Itoa(port)
+	count := 0
+	for _, fields := range rows {
+		if fields[3] == "ESTABLISHED" && strings.HasSuffix(fields[1], suffix) {
+			count++
+		}
+	}
+	return count, true
+}
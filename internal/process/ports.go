@@ -0,0 +1,213 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+const (
+	DefaultPortRangeStart = 20000
+	DefaultPortRangeEnd   = 29999
+)
+
+var ErrNoFreePort = errors.New("no free port left in the port range")
+
+// portAllocator hands out ports from a range and remembers them per process,
+// so a process keeps its ports across restarts and sessions.
+type portAllocator struct {
+	mu       sync.Mutex
+	path     string // empty keeps allocations in memory only
+	first    int
+	last     int
+	assigned map[string]map[string]int // process ID -> env var -> port
+}
+
+func newPortAllocator() *portAllocator {
+	return &portAllocator{
+		first:    DefaultPortRangeStart,
+		last:     DefaultPortRangeEnd,
+		assigned: make(map[string]map[string]int),
+	}
+}
+
+// setRange changes the range new ports are picked from; invalid ranges are
+// ignored
+func (p *portAllocator) setRange(first, last int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if first <= 0 || last > 65535 || first > last {
+		return
+	}
+	p.first, p.last = first, last
+}
+
+// load reads the allocations of a previous session. A missing or corrupt
+// file leaves the allocations empty.
+func (p *portAllocator) load(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.path = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var assigned map[string]map[string]int
+	if err := json.Unmarshal(data, &assigned); err != nil || assigned == nil {
+		return
+	}
+	p.assigned = assigned
+}
+
+// ports returns the ports named in names for process id, allocating the
+// missing ones and dropping the ones no longer requested
+func (p *portAllocator) ports(id string, names []string) (map[string]int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := p.assigned[id]
+	if current == nil {
+		current = make(map[string]int)
+	}
+	changed := false
+	for name := range current {
+		if !slices.Contains(names, name) {
+			delete(current, name)
+			changed = true
+		}
+	}
+	if len(names) == 0 {
+		if changed {
+			delete(p.assigned, id)
+			_ = p.save()
+		}
+		return nil, nil
+	}
+	result := make(map[string]int, len(names))
+	for _, name := range names {
+		port, ok := current[name]
+		if !ok {
+			var err error
+			if port, err = p.pick(p.taken()); err != nil {
+				return nil, fmt.Errorf("allocate %s: %w", name, err)
+			}
+			current[name] = port
+			p.assigned[id] = current
+			changed = true
+		}
+		result[name] = port
+	}
+	if changed {
+		_ = p.save()
+	}
+	return result, nil
+}
+
+// peek returns the ports already allocated to id without allocating any
+func (p *portAllocator) peek(id string) map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.assigned[id]) == 0 {
+		return nil
+	}
+	ports := make(map[string]int, len(p.assigned[id]))
+	for name, port := range p.assigned[id] {
+		ports[name] = port
+	}
+	return ports
+}
+
+// check returns the ports already allocated to id among names, and fails
+// when the range has no free port left for the missing ones. Unlike ports
+// it allocates and saves nothing.
+func (p *portAllocator) check(id string, names []string) (map[string]int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]int, len(names))
+	var taken map[int]bool
+	for _, name := range names {
+		if port, ok := p.assigned[id][name]; ok {
+			result[name] = port
+			continue
+		}
+		if taken == nil {
+			taken = p.taken()
+		}
+		port, err := p.pick(taken)
+		if err != nil {
+			return nil, fmt.Errorf("allocate %s: %w", name, err)
+		}
+		// Picked ports are free, so only the allocated ones are returned
+		taken[port] = true
+	}
+	return result, nil
+}
+
+// release forgets the ports of a removed process
+func (p *portAllocator) release(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.assigned[id]; ok {
+		delete(p.assigned, id)
+		_ = p.save()
+	}
+}
+
+// taken returns the allocated ports. Callers must hold p.mu.
+func (p *portAllocator) taken() map[int]bool {
+	taken := make(map[int]bool)
+	for _, ports := range p.assigned {
+		for _, port := range ports {
+			taken[port] = true
+		}
+	}
+	return taken
+}
+
+// pick finds a port in the range that is neither taken nor in use
+func (p *portAllocator) pick(taken map[int]bool) (int, error) {
+	for port := p.first; port <= p.last; port++ {
+		if !taken[port] && portFree(port) {
+			return port, nil
+		}
+	}
+	return 0, ErrNoFreePort
+}
+
+func (p *portAllocator) save() error {
+	if p.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.assigned, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, data, 0o644)
+}
+
+// portFree reports whether nothing listens on port
+func portFree(port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
@@ -0,0 +1,206 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrPreflightFailed = errors.New("preflight checks failed")
+
+// IssueLevel tells whether a preflight issue prevents a start
+type IssueLevel string
+
+const (
+	IssueError   IssueLevel = "error"   // The process cannot start as defined
+	IssueWarning IssueLevel = "warning" // The process may still start
+)
+
+// Issue is a problem found by Preflight.
+type Issue struct {
+	Level   IssueLevel `json:"level"`
+	Field   string     `json:"field"` // Definition field at fault, e.g. workingDir
+	Message string     `json:"message"`
+	PID     int        `json:"pid,omitempty"` // Process holding a port that is in use
+}
+
+// Preflight checks that def can start: the command resolves, the working
+// directory and env files are usable, references resolve and its ports and
+// sockets are free. Ports and sockets are not checked while the process is
+// running, as it holds them itself.
+func (m *Manager) Preflight(def Definition) []Issue {
+	issues := make([]Issue, 0)
+	add := func(level IssueLevel, field, format string, args ...any) {
+		issues = append(issues, Issue{Level: level, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if def.LevelPattern != "" {
+		if _, err := regexp.Compile(def.LevelPattern); err != nil {
+			add(IssueWarning, "levelPattern", "invalid level pattern: %v", err)
+		}
+	}
+	if def.Multiline.Continuation != "" {
+		if _, err := regexp.Compile(def.Multiline.Continuation); err != nil {
+			add(IssueWarning, "multiline", "invalid continuation pattern: %v", err)
+		}
+	}
+
+	if def.Kind == KindExternal {
+		match := def.External
+		if match.PIDFile == "" && match.PID <= 0 && match.Pattern == "" {
+			add(IssueError, "external", "%v", ErrNoExternalMatch)
+		} else if match.PIDFile == "" && match.PID <= 0 {
+			if _, err := regexp.Compile(match.Pattern); err != nil {
+				add(IssueError, "external", "invalid pattern: %v", err)
+			}
+		}
+		// Without a command there is nothing for ProcHub to run
+		if def.Command == "" {
+			return issues
+		}
+		if match.PIDFile == "" && match.PID > 0 {
+			add(IssueError, "external", "%v", ErrExternalPIDCommand)
+		}
+	}
+
+	if def.WorkingDir != "" {
+		if info, err := os.Stat(def.WorkingDir); err != nil {
+			add(IssueError, "workingDir", "working directory %s does not exist", def.WorkingDir)
+		} else if !info.IsDir() {
+			add(IssueError, "workingDir", "working directory %s is not a directory", def.WorkingDir)
+		} else if f, err := os.Open(def.WorkingDir); err != nil {
+			add(IssueError, "workingDir", "working directory %s is not accessible: %v", def.WorkingDir, err)
+		} else {
+			f.Close()
+		}
+	}
+
+	for _, path := range def.EnvFiles {
+		if _, err := loadEnvFile(envFilePath(def, path)); err != nil {
+			add(IssueError, "envFiles", "env file %s: %v", path, err)
+		}
+	}
+
+	m.mu.Lock()
+	// References are only looked up: the ports they name are allocated on
+	// start
+	for _, value := range referencingFields(def) {
+		if _, err := m.peekRefs(value); errors.Is(err, errPortUnallocated) {
+			add(IssueWarning, "references", "%v", err)
+		} else if err != nil {
+			add(IssueError, "references", "%v", err)
+		}
+	}
+	command, _ := m.peekRefs(def.Command)
+
+	var ports map[string]int
+	var sockets []Socket
+	item := m.entries[def.ID]
+	// Ports are allocated per ID, which a new process may not have yet.
+	// Only the ones allocated already are checked, without allocating more.
+	if def.ID != "" && (item == nil || !item.isActive()) {
+		var err error
+		if ports, err = m.ports.check(def.ID, def.Ports); err != nil {
+			add(IssueError, "ports", "%v", err)
+		}
+		if item == nil || item.sockets == nil {
+			sockets = def.Sockets
+		}
+	}
+	m.mu.Unlock()
+
+	switch {
+	case strings.TrimSpace(command) == "":
+		add(IssueError, "command", "command is empty")
+	case def.ShellMode:
+		shell := def.Shell
+		if shell == "" {
+			shell = defaultShell(def.LoginShell)
+		}
+		if _, err := exec.LookPath(shell); err != nil {
+			add(IssueError, "shell", "shell %s not found", shell)
+		}
+		// The first word may be a builtin or alias, so only warn
+		if word := strings.Fields(command)[0]; !strings.ContainsAny(word, "=$`'\"(") {
+			if _, err := exec.LookPath(commandPath(def, word)); err != nil {
+				add(IssueWarning, "command", "%s not found on PATH", word)
+			}
+		}
+	default:
+		if _, err := exec.LookPath(commandPath(def, command)); err != nil {
+			add(IssueError, "command", "%s not found on PATH or not executable", command)
+		}
+	}
+
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		port := ports[name]
+		if portFree(port) {
+			continue
+		}
+		issue := Issue{Level: IssueError, Field: "ports", Message: fmt.Sprintf("port %d (%s) is already in use", port, name)}
+		if pid := portOwner(port); pid != 0 {
+			issue.PID = pid
+			issue.Message = fmt.Sprintf("port %d (%s) is already in use by pid %d", port, name, pid)
+		}
+		issues = append(issues, issue)
+	}
+
+	for _, spec := range sockets {
+		l, err := listen(spec)
+		if err != nil {
+			add(IssueError, "sockets", "cannot bind %s %s: %v", spec.network(), spec.Address, err)
+			continue
+		}
+		if f, err := listenerFile(l); err != nil {
+			add(IssueError, "sockets", "cannot pass %s %s: %v", spec.network(), spec.Address, err)
+		} else {
+			f.Close()
+		}
+		l.Close()
+	}
+	return issues
+}
+
+// PreflightError returns an error listing the error-level issues, or nil if
+// there are none.
+func PreflightError(issues []Issue) error {
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		if issue.Level == IssueError {
+			messages = append(messages, issue.Message)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPreflightFailed, strings.Join(messages, "; "))
+}
+
+// referencingFields returns the fields that may reference other processes
+func referencingFields(def Definition) []string {
+	fields := []string{def.Command, def.Readiness.Target}
+	fields = append(fields, def.Args...)
+	for _, value := range def.Env {
+		fields = append(fields, value)
+	}
+	return fields
+}
+
+// commandPath resolves a relative command path against the working
+// directory the way exec.Cmd does
+func commandPath(def Definition, command string) string {
+	if def.WorkingDir == "" || filepath.IsAbs(command) || !strings.ContainsRune(command, filepath.Separator) {
+		return command
+	}
+	return filepath.Join(def.WorkingDir, command)
+}
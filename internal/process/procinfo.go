@@ -0,0 +1,40 @@
+package process
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// procInfo describes a process running on the system, whoever started it
+type procInfo struct {
+	PID         int
+	PPID        int
+	Name        string
+	CommandLine string
+	StartedAt   time.Time
+}
+
+// procUsage is a resource usage sample of a process
+type procUsage struct {
+	CPUSeconds float64 // user and system CPU time so far
+	RSSBytes   uint64
+	StartedAt  time.Time
+}
+
+// parseCPUTime parses ps style durations such as "1-02:03:04", "02:03:04"
+// or "3:04.56"
+func parseCPUTime(s string) float64 {
+	days := 0.0
+	if d, rest, ok := strings.Cut(s, "-"); ok {
+		days, _ = strconv.ParseFloat(d, 64)
+		s = rest
+	}
+	parts := strings.Split(s, ":")
+	total := 0.0
+	for _, part := range parts {
+		v, _ := strconv.ParseFloat(part, 64)
+		total = total*60 + v
+	}
+	return days*86400 + total
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, which is 100 on every Linux platform we run on
+const clockTicks = 100
+
+// listProcesses returns the processes visible in /proc. Kernel threads,
+// which have no command line, are left out.
+func listProcesses() ([]procInfo, error) {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	boot := bootTime()
+	procs := make([]procInfo, 0, len(dirs))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		fields, comm := readStat(pid)
+		if len(fields) < 20 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		ticks, _ := strconv.ParseUint(fields[19], 10, 64)
+		procs = append(procs, procInfo{
+			PID:         pid,
+			PPID:        ppid,
+			Name:        comm,
+			CommandLine: strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " ")),
+			StartedAt:   boot.Add(time.Duration(ticks) * time.Second / clockTicks),
+		})
+	}
+	return procs, nil
+}
+
+// processUsage reads the CPU time, resident memory and start time of pid
+func processUsage(pid int) (procUsage, error) {
+	fields, _ := readStat(pid)
+	if len(fields) < 22 {
+		return procUsage{}, ErrNotRunning
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	startTicks, _ := strconv.ParseUint(fields[19], 10, 64)
+	rssPages, _ := strconv.ParseUint(fields[21], 10, 64)
+	return procUsage{
+		CPUSeconds: float64(utime+stime) / clockTicks,
+		RSSBytes:   rssPages * uint64(os.Getpagesize()),
+		StartedAt:  bootTime().Add(time.Duration(startTicks) * time.Second / clockTicks),
+	}, nil
+}
+
+// readStat returns the fields of /proc/<pid>/stat after "pid (comm)",
+// so fields[0] is the state (field 3 overall), and the command name
+func readStat(pid int) ([]string, string) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return nil, ""
+	}
+	// The command name may contain spaces, so split after its closing paren.
+	stat := string(data)
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return nil, ""
+	}
+	return strings.Fields(stat[end+1:]), stat[start+1 : end]
+}
+
+// bootTime returns when the system booted, from /proc/stat
+func bootTime() time.Time {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, _ := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			return time.Unix(secs, 0)
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,63 @@
+//go:build !linux && !windows
+
+package process
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// psTimeLayout is the format of the ps lstart column
+const psTimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// listProcesses returns the processes reported by ps
+func listProcesses() ([]procInfo, error) {
+	out, err := exec.Command("ps", "-axww", "-o", "pid=,ppid=,lstart=,command=").Output()
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]procInfo, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		// pid, ppid, five lstart words, then the command line
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		startedAt, _ := time.ParseInLocation(psTimeLayout, strings.Join(fields[2:7], " "), time.Local)
+		procs = append(procs, procInfo{
+			PID:         pid,
+			PPID:        ppid,
+			Name:        filepath.Base(fields[7]),
+			CommandLine: strings.Join(fields[7:], " "),
+			StartedAt:   startedAt,
+		})
+	}
+	return procs, nil
+}
+
+// processUsage reads the CPU time, resident memory and start time of pid
+func processUsage(pid int) (procUsage, error) {
+	out, err := exec.Command("ps", "-o", "time=,rss=,lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return procUsage{}, ErrNotRunning
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 7 {
+		return procUsage{}, ErrNotRunning
+	}
+	rssKB, _ := strconv.ParseUint(fields[1], 10, 64)
+	startedAt, _ := time.ParseInLocation(psTimeLayout, strings.Join(fields[2:7], " "), time.Local)
+	return procUsage{
+		CPUSeconds: parseCPUTime(fields[0]),
+		RSSBytes:   rssKB * 1024,
+		StartedAt:  startedAt,
+	}, nil
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package process
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// powershell runs a PowerShell script without showing a window
+func powershell(script string) (string, error) {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// listProcesses returns the processes reported by WMI
+func listProcesses() ([]procInfo, error) {
+	out, err := powershell("Get-CimInstance Win32_Process | ForEach-Object { " +
+		"$t = if ($_.CreationDate) { $_.CreationDate.ToUniversalTime().ToString('o') } else { '' }; " +
+		"\"{0}`t{1}`t{2}`t{3}`t{4}\" -f $_.ProcessId, $_.ParentProcessId, $t, $_.Name, $_.CommandLine }")
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]procInfo, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 5)
+		if len(fields) < 5 || fields[4] == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		startedAt, _ := time.Parse(time.RFC3339Nano, fields[2])
+		procs = append(procs, procInfo{
+			PID:         pid,
+			PPID:        ppid,
+			Name:        strings.TrimSuffix(fields[3], filepath.Ext(fields[3])),
+			CommandLine: fields[4],
+			StartedAt:   startedAt,
+		})
+	}
+	return procs, nil
+}
+
+// processUsage reads the CPU time, working set and start time of pid
+func processUsage(pid int) (procUsage, error) {
+	out, err := powershell("$p = Get-Process -Id " + strconv.Itoa(pid) + "; " +
+		"[string]::Format([Globalization.CultureInfo]::InvariantCulture, \"{0}`t{1}`t{2}\", " +
+		"$p.TotalProcessorTime.TotalSeconds, $p.WorkingSet64, $p.StartTime.ToUniversalTime().ToString('o'))")
+	if err != nil {
+		return procUsage{}, ErrNotRunning
+	}
+	fields := strings.Split(strings.TrimSpace(out), "\t")
+	if len(fields) < 3 {
+		return procUsage{}, ErrNotRunning
+	}
+	cpu, _ := strconv.ParseFloat(fields[0], 64)
+	rss, _ := strconv.ParseUint(fields[1], 10, 64)
+	startedAt, _ := time.Parse(time.RFC3339Nano, fields[2])
+	return procUsage{CPUSeconds: cpu, RSSBytes: rss, StartedAt: startedAt}, nil
+}
@@ -0,0 +1,180 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultReadyTimeout bounds a readiness check without its own timeout
+	defaultReadyTimeout = 30 * time.Second
+
+	// defaultReadyDelay is how long a process without a readiness check
+	// must stay up to count as ready
+	defaultReadyDelay = 2 * time.Second
+
+	readyProbeInterval = 200 * time.Millisecond
+)
+
+var errExitedBeforeReady = errors.New("process exited before becoming ready")
+
+// ReadinessType selects how ProcHub decides that a process is ready.
+type ReadinessType string
+
+const (
+	ReadyTCP   ReadinessType = "tcp"   // Target is a host:port that accepts connections
+	ReadyHTTP  ReadinessType = "http"  // Target is a URL answering with a non-error status
+	ReadyLog   ReadinessType = "log"   // Target is a regexp matched against output lines
+	ReadyDelay ReadinessType = "delay" // Target is how long the process must stay up, e.g. "3s"
+)
+
+// ReadinessCheck tells when a started process is ready to serve. While the
+// check is pending the process is reported as starting.
+type ReadinessCheck struct {
+	Type    ReadinessType `json:"type"`
+	Target  string        `json:"target"`
+	Timeout int           `json:"timeout"` // seconds, defaults to 30
+}
+
+// waitReady blocks until inst passes check, exits, or the check times out.
+// With own set a TCP or HTTP check only passes once inst itself listens on
+// the probed port, so that a rolling restart does not take the answer of the
+// instance it replaces for the new one.
+func waitReady(ctx context.Context, inst *instance, check ReadinessCheck, own bool) error {
+	timeout := time.Duration(check.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultReadyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch check.Type {
+	case "", ReadyDelay:
+		delay := defaultReadyDelay
+		if check.Target != "" {
+			d, err := time.ParseDuration(check.Target)
+			if err != nil {
+				return fmt.Errorf("invalid readiness delay %q: %w", check.Target, err)
+			}
+			delay = d
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-inst.exited:
+			return errExitedBeforeReady
+		case <-ctx.Done():
+			return fmt.Errorf("readiness check timed out: %w", ctx.Err())
+		}
+	case ReadyLog:
+		if inst.readyPattern == nil {
+			return fmt.Errorf("invalid readiness pattern %q", check.Target)
+		}
+		select {
+		case <-inst.ready:
+			return nil
+		case <-inst.exited:
+			return errExitedBeforeReady
+		case <-ctx.Done():
+			return fmt.Errorf("readiness check timed out: %w", ctx.Err())
+		}
+	case ReadyTCP, ReadyHTTP:
+		ticker := time.NewTicker(readyProbeInterval)
+		defer ticker.Stop()
+		var lastErr error
+		for {
+			if lastErr = probe(ctx, check); lastErr == nil {
+				if !own {
+					return nil
+				}
+				if lastErr = probeOwner(inst, check); lastErr == nil {
+					return nil
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-inst.exited:
+				return errExitedBeforeReady
+			case <-ctx.Done():
+				return fmt.Errorf("readiness check timed out: %v", lastErr)
+			}
+		}
+	}
+	return fmt.Errorf("unknown readiness check type %q", check.Type)
+}
+
+// probe runs a single TCP or HTTP readiness probe
+func probe(ctx context.Context, check ReadinessCheck) error {
+	probeCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	if check.Type == ReadyTCP {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(probeCtx, "tcp", check.Target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(probeCtx, http.MethodGet, check.Target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeOwner checks that the port probed by a TCP or HTTP check belongs to
+// inst or one of its children
+func probeOwner(inst *instance, check ReadinessCheck) error {
+	port, err := targetPort(check)
+	if err != nil {
+		return err
+	}
+	listens, known := listensOn(inst.cmd.Process.Pid, port)
+	if !known {
+		return fmt.Errorf("cannot tell which process listens on port %d, use a log readiness check", port)
+	}
+	if !listens {
+		return fmt.Errorf("port %d is not served by the new instance", port)
+	}
+	return nil
+}
+
+// targetPort returns the port a TCP or HTTP check probes
+func targetPort(check ReadinessCheck) (int, error) {
+	if check.Type == ReadyTCP {
+		_, port, err := net.SplitHostPort(check.Target)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(port)
+	}
+	u, err := url.Parse(check.Target)
+	if err != nil {
+		return 0, err
+	}
+	if port := u.Port(); port != "" {
+		return strconv.Atoi(port)
+	}
+	if u.Scheme == "https" {
+		return 443, nil
+	}
+	return 80, nil
+}
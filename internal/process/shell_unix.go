@@ -0,0 +1,31 @@
+//go:build !windows
+
+package process
+
+import (
+	"os"
+	"os/exec"
+)
+
+// defaultShell returns /bin/sh, or the user's $SHELL when a login shell is
+// requested
+func defaultShell(login bool) string {
+	if login {
+		if shell := os.Getenv("SHELL"); shell != "" {
+			return shell
+		}
+	}
+	return "/bin/sh"
+}
+
+// shellArgs returns the arguments that make a shell run line
+func shellArgs(login bool, line string) []string {
+	if login {
+		return []string{"-l", "-c", line}
+	}
+	return []string{"-c", line}
+}
+
+// setShellCmdLine is a no-op on Unix-like systems, where the line is passed
+// to the shell as a single argument
+func setShellCmdLine(cmd *exec.Cmd, line string) {}
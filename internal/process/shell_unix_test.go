@@ -0,0 +1,55 @@
+//go:build !windows
+
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShellModePipeline(t *testing.T) {
+	m := NewManager()
+	logs := recordLogs(m)
+	m.Register(Definition{
+		ID:            "proc-1",
+		Command:       "echo hello | tr a-z A-Z",
+		ShellMode:     true,
+		RestartPolicy: RestartNever,
+	})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	logs.waitLine(t, "stdout", "HELLO")
+}
+
+func TestShellModeStopTerminatesPipeline(t *testing.T) {
+	m := NewManager()
+	m.Register(Definition{
+		ID:            "proc-1",
+		Command:       "sleep 30 | cat",
+		ShellMode:     true,
+		RestartPolicy: RestartNever,
+	})
+	if err := m.Start(context.Background(), "proc-1"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	var pid int
+	deadline := time.Now().Add(3 * time.Second)
+	for pid == 0 && time.Now().Before(deadline) {
+		snap, _ := m.Get("proc-1")
+		pid = snap.PID
+		time.Sleep(20 * time.Millisecond)
+	}
+	if pid == 0 {
+		t.Fatal("process did not start")
+	}
+
+	if err := m.Stop("proc-1"); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if isProcessGroupAlive(pid) {
+		t.Error("expected every stage of the pipeline to be stopped")
+	}
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// defaultShell returns the command interpreter. Windows has no login shell,
+// so login is ignored
+func defaultShell(login bool) string {
+	if comspec := os.Getenv("ComSpec"); comspec != "" {
+		return comspec
+	}
+	return "cmd.exe"
+}
+
+// shellArgs returns the arguments that make cmd.exe run line
+func shellArgs(login bool, line string) []string {
+	return []string{"/S", "/C", line}
+}
+
+// setShellCmdLine passes line to cmd.exe untouched. Go would otherwise escape
+// quotes the way the C runtime expects, which cmd.exe does not understand
+func setShellCmdLine(cmd *exec.Cmd, line string) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = syscall.EscapeArg(cmd.Path) + ` /S /C "` + line + `"`
+}
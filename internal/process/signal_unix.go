@@ -3,8 +3,11 @@
 package process
 
 import (
+	"errors"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -65,9 +68,10 @@ func isProcessRunning(pid int) bool {
 	if err != nil {
 		return false
 	}
-	// On Unix, FindProcess always succeeds, so we need to send signal 0
+	// On Unix, FindProcess always succeeds, so we need to send signal 0.
+	// EPERM means it exists but belongs to another user.
 	err = process.Signal(syscall.Signal(0))
-	return err == nil
+	return err == nil || errors.Is(err, syscall.EPERM)
 }
 
 // getExitCode returns the exit code of a finished process
@@ -77,3 +81,60 @@ func getExitCode(cmd *exec.Cmd) int {
 	}
 	return cmd.ProcessState.ExitCode()
 }
+
+// isProcessGroupAlive reports whether any member of the process group led
+// by pgid is still running
+func isProcessGroupAlive(pgid int) bool {
+	err := syscall.Kill(-pgid, syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// terminateProcessGroup sends SIGTERM to a process group we have no
+// *exec.Cmd for, such as one left behind by a previous session
+func terminateProcessGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGTERM)
+}
+
+// killProcessGroup sends SIGKILL to a process group we have no *exec.Cmd for
+func killProcessGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// signalNames maps the signals users may send by name
+var signalNames = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"TERM":  syscall.SIGTERM,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// sendSignal sends the named signal (e.g. "HUP", "SIGHUP" or "1") to the
+// process, or to its whole process group when group is set
+func sendSignal(pid int, name string, group bool) error {
+	sig, err := parseSignal(name)
+	if err != nil {
+		return err
+	}
+	if group {
+		return syscall.Kill(-pid, sig)
+	}
+	return syscall.Kill(pid, sig)
+}
+
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	return 0, ErrUnsupportedSignal
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -95,3 +96,60 @@ func getExitCode(cmd *exec.Cmd) int {
 	}
 	return cmd.ProcessState.ExitCode()
 }
+
+// isProcessGroupAlive reports whether the group leader is still running.
+// Windows has no process groups we can probe, so the leader stands in.
+func isProcessGroupAlive(pid int) bool {
+	return isProcessRunning(pid)
+}
+
+// terminateProcessGroup kills a process tree we have no *exec.Cmd for, such
+// as one left behind by a previous session
+func terminateProcessGroup(pid int) error {
+	return killProcessGroup(pid)
+}
+
+// killProcessGroup kills a process tree we have no *exec.Cmd for
+func killProcessGroup(pid int) error {
+	kill := exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(pid))
+	kill.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true,
+	}
+	return kill.Run()
+}
+
+// sendSignal emulates a few Unix signals on Windows: INT and BREAK become a
+// CTRL_BREAK_EVENT for the process group, as Windows ignores CTRL_C_EVENT
+// sent to a group other than all consoles. TERM and KILL terminate the
+// process (tree, when group is set). Anything else is unsupported.
+func sendSignal(pid int, name string, group bool) error {
+	name = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+	switch name {
+	case "INT", "BREAK":
+		// Console events are delivered per process group; the group ID
+		// is the PID of the leader we created with CREATE_NEW_PROCESS_GROUP
+		const event = 1 // CTRL_BREAK_EVENT
+		dll, err := syscall.LoadDLL("kernel32.dll")
+		if err != nil {
+			return err
+		}
+		proc, err := dll.FindProc("GenerateConsoleCtrlEvent")
+		if err != nil {
+			return err
+		}
+		if r, _, err := proc.Call(event, uintptr(pid)); r == 0 {
+			return err
+		}
+		return nil
+	case "TERM", "KILL":
+		if group {
+			return killProcessGroup(pid)
+		}
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return err
+		}
+		return process.Kill()
+	}
+	return ErrUnsupportedSignal
+}
@@ -0,0 +1,219 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSocketsUnsupported = errors.New("socket activation is not supported on this platform")
+
+// socketSet holds the listening sockets of a process. They are bound once
+// and handed to every instance, so connections queue up in the kernel while
+// the process restarts instead of being refused.
+type socketSet struct {
+	specs     []Socket
+	listeners []net.Listener
+	files     []*os.File // duplicates passed to children as fd 3 onwards
+	watching  bool       // on-demand watchers are running
+	closed    chan struct{}
+}
+
+// bindSockets binds every socket in specs, closing the ones already bound if
+// one of them fails.
+func bindSockets(specs []Socket) (*socketSet, error) {
+	set := &socketSet{specs: specs, closed: make(chan struct{})}
+	for _, spec := range specs {
+		l, err := listen(spec)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("bind %s %s: %w", spec.network(), spec.Address, err)
+		}
+		set.listeners = append(set.listeners, l)
+
+		f, err := listenerFile(l)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("bind %s %s: %w", spec.network(), spec.Address, err)
+		}
+		set.files = append(set.files, f)
+	}
+	return set, nil
+}
+
+func listen(spec Socket) (net.Listener, error) {
+	network := spec.network()
+	if network == "unix" {
+		removeStaleSocket(spec.Address)
+	}
+	return net.Listen(network, spec.Address)
+}
+
+// removeStaleSocket removes a unix socket file left by a previous session,
+// which would make the bind fail
+func removeStaleSocket(path string) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		// Someone is serving it, let the bind fail
+		conn.Close()
+		return
+	}
+	_ = os.Remove(path)
+}
+
+func (s *socketSet) close() {
+	close(s.closed)
+	for _, f := range s.files {
+		f.Close()
+	}
+	for _, l := range s.listeners {
+		l.Close()
+	}
+}
+
+// addresses returns the bound addresses, which differ from the configured
+// ones when binding to port 0
+func (s *socketSet) addresses() []string {
+	addrs := make([]string, 0, len(s.listeners))
+	for _, l := range s.listeners {
+		addrs = append(addrs, l.Addr().String())
+	}
+	return addrs
+}
+
+// env returns the socket activation variables except LISTEN_PID, which only
+// the child itself can know
+func (s *socketSet) env() []string {
+	names := make([]string, 0, len(s.specs))
+	for i, spec := range s.specs {
+		name := spec.Name
+		if name == "" {
+			name = "socket" + strconv.Itoa(i)
+		}
+		names = append(names, name)
+	}
+	return []string{
+		"LISTEN_FDS=" + strconv.Itoa(len(s.files)),
+		"LISTEN_FDNAMES=" + strings.Join(names, ":"),
+	}
+}
+
+func (spec Socket) network() string {
+	if spec.Network == "" {
+		return "tcp"
+	}
+	return spec.Network
+}
+
+// sameSockets reports whether a redefined process can keep its bound sockets
+func sameSockets(a, b Definition) bool {
+	return slices.Equal(a.Sockets, b.Sockets) && a.OnDemand == b.OnDemand && a.Lazy == b.Lazy
+}
+
+// sockets returns the bound sockets of the entry, binding them on first use.
+// It binds without holding m.mu and keeps the set bound first should
+// another caller race it.
+func (m *Manager) sockets(id string) (*socketSet, error) {
+	m.mu.RLock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.RUnlock()
+		return nil, ErrNotFound
+	}
+	set, specs := item.sockets, item.definition.Sockets
+	m.mu.RUnlock()
+	if set != nil || len(specs) == 0 {
+		return set, nil
+	}
+
+	bound, err := bindSockets(specs)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.entries[id]; !ok || current != item {
+		// Removed or redefined while binding
+		bound.close()
+		return nil, ErrNotFound
+	}
+	if item.sockets != nil {
+		bound.close()
+		return item.sockets, nil
+	}
+	item.sockets = bound
+	return bound, nil
+}
+
+// ListenSockets binds the sockets of the process ahead of its first start.
+// For an on-demand or lazy process it also watches them and starts the
+// process when a connection arrives while it is not running, or for a lazy
+// process while it is idle.
+func (m *Manager) ListenSockets(ctx context.Context, id string) error {
+	set, err := m.sockets(id)
+	if err != nil || set == nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.entries[id]
+	if !ok || item.sockets != set {
+		return ErrNotFound
+	}
+	if (item.definition.OnDemand || item.definition.Lazy) && !set.watching {
+		set.watching = true
+		for _, f := range set.files {
+			go m.watchSocket(ctx, id, set, f)
+		}
+	}
+	return nil
+}
+
+// watchSocket starts an on-demand process whenever a connection is pending
+// on the socket f and the process is not running. It returns once the
+// sockets are closed.
+func (m *Manager) watchSocket(ctx context.Context, id string, set *socketSet, f *os.File) {
+	fd := f.Fd()
+	for {
+		if err := waitConnection(fd, set.closed); err != nil {
+			return
+		}
+
+		m.mu.Lock()
+		item, ok := m.entries[id]
+		if !ok || item.sockets != set {
+			m.mu.Unlock()
+			return
+		}
+		done := item.done
+		if !item.isActive() {
+			_, orphaned := m.orphans[id]
+			stopped := item.definition.Lazy && !item.definition.OnDemand && item.status != StatusIdle
+			if orphaned || stopped || item.status == StatusFatal {
+				// Leave the connection queued until the user steps in
+				m.mu.Unlock()
+				time.Sleep(time.Second)
+				continue
+			}
+			done = m.startLocked(ctx, id, item)
+		}
+		m.mu.Unlock()
+
+		// The process accepts the connection; look again once it is gone
+		if done != nil {
+			<-done
+		} else {
+			time.Sleep(time.Second)
+		}
+	}
+}
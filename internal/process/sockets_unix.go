@@ -0,0 +1,59 @@
+//go:build !windows
+
+package process
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenActivationScript exports the PID of the child as LISTEN_PID and then
+// execs the real command, which keeps that PID
+const listenActivationScript = `LISTEN_PID=$$; export LISTEN_PID; exec "$0" "$@"`
+
+// listenerFile returns a duplicate of the listener's descriptor for passing
+// to children
+func listenerFile(l net.Listener) (*os.File, error) {
+	fl, ok := l.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, ErrSocketsUnsupported
+	}
+	return fl.File()
+}
+
+// attachSockets passes the sockets to cmd as fd 3 onwards following the
+// systemd socket activation protocol
+func attachSockets(cmd *exec.Cmd, set *socketSet) {
+	cmd.ExtraFiles = append(cmd.ExtraFiles, set.files...)
+	cmd.Env = append(cmd.Env, set.env()...)
+	if cmd.Err != nil {
+		return
+	}
+	args := []string{"/bin/sh", "-c", listenActivationScript, cmd.Path}
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+}
+
+// waitConnection blocks until a connection is pending on the listening
+// socket fd without accepting it, or until closed is closed
+func waitConnection(fd uintptr, closed <-chan struct{}) error {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, 250)
+		select {
+		case <-closed:
+			return net.ErrClosed
+		default:
+		}
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package process
+
+import (
+	"net"
+	"os"
+	"os/exec"
+)
+
+// listenerFile is not supported on Windows, which has no LISTEN_FDS
+// equivalent
+func listenerFile(l net.Listener) (*os.File, error) {
+	return nil, ErrSocketsUnsupported
+}
+
+// attachSockets is a no-op on Windows, where no sockets are ever bound
+func attachSockets(cmd *exec.Cmd, set *socketSet) {}
+
+// waitConnection is not supported on Windows
+func waitConnection(fd uintptr, closed <-chan struct{}) error {
+	return ErrSocketsUnsupported
+}
@@ -0,0 +1,104 @@
+package process
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateRecord describes a live process group started by ProcHub. Records are
+// persisted so the next session can find children left behind by a crash.
+type stateRecord struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	PID        int       `json:"pid"`
+	Command    string    `json:"command"`
+	StartedAt  time.Time `json:"startedAt"`
+	StartTicks uint64    `json:"startTicks,omitempty"` // Kernel start time, used to detect PID reuse
+}
+
+// stateFile keeps the set of live process groups on disk.
+type stateFile struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]stateRecord
+}
+
+func newStateFile(path string) *stateFile {
+	return &stateFile{
+		path:    path,
+		records: make(map[string]stateRecord),
+	}
+}
+
+// load reads the records written by a previous session. A missing or
+// corrupt file yields an empty set.
+func (s *stateFile) load() map[string]stateRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make(map[string]stateRecord)
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return records
+	}
+	var list []stateRecord
+	if err := json.Unmarshal(data, &list); err != nil {
+		return records
+	}
+	for _, rec := range list {
+		records[rec.ID] = rec
+	}
+	return records
+}
+
+func (s *stateFile) put(rec stateRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.ID] = rec
+	_ = s.save()
+}
+
+// remove drops the record for id, but only if it still refers to pid so a
+// late exit of an old instance does not forget a newer one.
+func (s *stateFile) remove(id string, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[id]; !ok || rec.PID != pid {
+		return
+	}
+	delete(s.records, id)
+	_ = s.save()
+}
+
+func (s *stateFile) save() error {
+	list := make([]stateRecord, 0, len(s.records))
+	for _, rec := range s.records {
+		list = append(list, rec)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o644)
+}
+
+// isSameProcess reports whether rec still refers to the process group that
+// was recorded, guarding against the PID having been reused.
+func isSameProcess(rec stateRecord) bool {
+	if !isProcessGroupAlive(rec.PID) {
+		return false
+	}
+	if rec.StartTicks == 0 {
+		return true
+	}
+	ticks := processStartTicks(rec.PID)
+	// The leader may have exited while other group members live on; a PGID
+	// cannot be reused while the group exists, so the group is still ours.
+	return ticks == 0 || ticks == rec.StartTicks
+}
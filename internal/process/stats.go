@@ -0,0 +1,64 @@
+package process
+
+import "time"
+
+// cpuSample is the CPU time of a process at a point in time
+type cpuSample struct {
+	cpuSeconds float64
+	at         time.Time
+}
+
+// Stats returns the resource usage of the running process, whether started
+// by us, adopted or external. CPU usage covers the time since the previous
+// call, or the whole lifetime of the process on the first one.
+func (m *Manager) Stats(id string) (ProcessStats, error) {
+	m.mu.RLock()
+	item, ok := m.entries[id]
+	if !ok {
+		m.mu.RUnlock()
+		return ProcessStats{}, ErrNotFound
+	}
+	pid := item.pid()
+	m.mu.RUnlock()
+
+	if pid == 0 {
+		return ProcessStats{}, ErrNotRunning
+	}
+	usage, err := processUsage(pid)
+	if err != nil {
+		return ProcessStats{}, err
+	}
+
+	now := time.Now()
+	stats := ProcessStats{
+		PID:      pid,
+		MemoryMB: float64(usage.RSSBytes) / (1 << 20),
+	}
+	if !usage.StartedAt.IsZero() {
+		stats.Uptime = int64(now.Sub(usage.StartedAt).Seconds())
+	}
+
+	m.mu.Lock()
+	prev, sampled := m.samples[pid]
+	m.samples[pid] = cpuSample{cpuSeconds: usage.CPUSeconds, at: now}
+	// Forget processes that are gone
+	for samplePID := range m.samples {
+		if samplePID != pid && !isProcessRunning(samplePID) {
+			delete(m.samples, samplePID)
+		}
+	}
+	m.mu.Unlock()
+
+	var elapsed, cpu float64
+	if sampled && usage.CPUSeconds >= prev.cpuSeconds {
+		elapsed = now.Sub(prev.at).Seconds()
+		cpu = usage.CPUSeconds - prev.cpuSeconds
+	} else if !usage.StartedAt.IsZero() {
+		elapsed = now.Sub(usage.StartedAt).Seconds()
+		cpu = usage.CPUSeconds
+	}
+	if elapsed > 0 {
+		stats.CPUPercent = cpu / elapsed * 100
+	}
+	return stats, nil
+}
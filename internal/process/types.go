@@ -9,8 +9,38 @@ const (
 	StatusStopped  Status = "stopped"
 	StatusErrored  Status = "errored"
 	StatusStarting Status = "starting"
+
+	// Terminal states of a task run
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+
+	// Crash loop detected: backoff retries after a cooldown, fatal waits
+	// for the user
+	StatusBackoff Status = "backoff"
+	StatusFatal   Status = "fatal"
+
+	// A lazy process waiting for traffic
+	StatusIdle Status = "idle"
+)
+
+// Kind tells long-running services apart from one-shot tasks such as build
+// or migration scripts.
+type Kind string
+
+const (
+	KindService  Kind = "service"
+	KindTask     Kind = "task"     // Runs to completion; never restarted after success
+	KindExternal Kind = "external" // Started by other means; found with External and monitored
 )
 
+// ExternalMatch finds a process ProcHub did not start. The first non-empty
+// field is used.
+type ExternalMatch struct {
+	PIDFile string `json:"pidFile"` // File holding the PID, e.g. /run/nginx.pid
+	PID     int    `json:"pid"`
+	Pattern string `json:"pattern"` // Regexp matched against full command lines
+}
+
 type RestartPolicy string
 
 const (
@@ -19,29 +49,117 @@ const (
 	RestartNever     RestartPolicy = "never"
 )
 
+// OrphanPolicy decides what happens to process groups left behind by a
+// previous ProcHub session that did not shut down cleanly.
+type OrphanPolicy string
+
+const (
+	OrphanKill  OrphanPolicy = "kill"  // Terminate leftover process groups on startup
+	OrphanAdopt OrphanPolicy = "adopt" // Track leftover process groups as running
+	OrphanKeep  OrphanPolicy = "keep"  // Leave them alone and let the user decide
+)
+
+// CrashLoopPolicy configures crash-loop detection: MaxFailures failed runs
+// within Window seconds stop the restarts for Cooldown seconds. Zero values
+// fall back to the defaults; a negative MaxFailures disables detection and a
+// negative Cooldown waits for the user instead of resuming automatically.
+type CrashLoopPolicy struct {
+	MaxFailures int `json:"maxFailures"`
+	Window      int `json:"window"`   // seconds
+	Cooldown    int `json:"cooldown"` // seconds
+}
+
+// CrashLoopState exposes the crash-loop detector counters of a process.
+type CrashLoopState struct {
+	Failures     int        `json:"failures"` // Failed runs within the current window
+	Trips        int        `json:"trips"`    // Times a crash loop was detected
+	BackoffUntil *time.Time `json:"backoffUntil,omitempty"`
+}
+
+// ReloadAction tells ProcHub how to make a running process reload, either by
+// sending it a signal or by running a command. The command runs through the
+// shell in the process's working directory and environment with
+// PROCHUB_PID set to the process ID.
+type ReloadAction struct {
+	Signal  string `json:"signal"`  // e.g. HUP
+	Group   bool   `json:"group"`   // Send Signal to the whole process group
+	Command string `json:"command"` // Used when Signal is empty
+}
+
+// RestartMode selects how a running process is restarted on request.
+type RestartMode string
+
+const (
+	RestartModeStopStart RestartMode = "stop_start" // Stop, then start (default)
+	RestartModeRolling   RestartMode = "rolling"    // Start a new instance before stopping the old one
+)
+
+// Socket is a listening socket that ProcHub binds once and passes to every
+// start of the process using the systemd socket activation protocol
+// (LISTEN_FDS, LISTEN_PID and LISTEN_FDNAMES), so it survives restarts.
+type Socket struct {
+	Name    string `json:"name"`    // Reported in LISTEN_FDNAMES, defaults to socketN
+	Network string `json:"network"` // tcp (default), tcp4, tcp6 or unix
+	Address string `json:"address"` // e.g. 127.0.0.1:8080 or /tmp/app.sock
+}
+
 type Environment map[string]string
 
 type Definition struct {
-	ID            string        `json:"id"`
-	Name          string        `json:"name"`
-	Command       string        `json:"command"`
-	Args          []string      `json:"args"`
-	WorkingDir    string        `json:"workingDir"`
-	Env           Environment   `json:"env"`
-	AutoStart     bool          `json:"autoStart"`     // Auto-start on app launch
-	AutoRestart   bool          `json:"autoRestart"`   // Deprecated: use RestartPolicy
-	RestartPolicy RestartPolicy `json:"restartPolicy"` // Restart policy
-	MaxRetries    int           `json:"maxRetries"`    // Max restart attempts
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Kind           Kind            `json:"kind"` // service (default), task or external
+	Command        string          `json:"command"`
+	Args           []string        `json:"args"`
+	WorkingDir     string          `json:"workingDir"`
+	Env            Environment     `json:"env"`
+	EnvFiles       []string        `json:"envFiles"`       // dotenv files loaded before Env, relative to WorkingDir
+	AutoStart      bool            `json:"autoStart"`      // Auto-start on app launch
+	AutoRestart    bool            `json:"autoRestart"`    // Deprecated: use RestartPolicy
+	RestartPolicy  RestartPolicy   `json:"restartPolicy"`  // Restart policy
+	MaxRetries     int             `json:"maxRetries"`     // Max restart attempts
+	SuccessCodes   []int           `json:"successCodes"`   // Exit codes treated as success, defaults to [0]
+	NoRestartCodes []int           `json:"noRestartCodes"` // Exit codes that never trigger a restart
+	RestartCodes   []int           `json:"restartCodes"`   // Exit codes that always trigger a restart
+	Detached       bool            `json:"detached"`       // Keep running if ProcHub exits unexpectedly
+	ShellMode      bool            `json:"shellMode"`      // Run the command line through a shell
+	Shell          string          `json:"shell"`          // Shell for ShellMode, defaults to /bin/sh (cmd.exe on Windows)
+	LoginShell     bool            `json:"loginShell"`     // Use the user's login shell for ShellMode
+	CrashLoop      CrashLoopPolicy `json:"crashLoop"`      // Crash-loop detection
+	Reload         ReloadAction    `json:"reload"`         // Reload action
+	Readiness      ReadinessCheck  `json:"readiness"`      // When a started process counts as ready
+	RestartMode    RestartMode     `json:"restartMode"`    // How RestartProcess restarts a running process
+	Sockets        []Socket        `json:"sockets"`        // Listening sockets passed via LISTEN_FDS
+	OnDemand       bool            `json:"onDemand"`       // Start on the first connection to Sockets
+	Ports          []string        `json:"ports"`          // Env vars to receive an allocated free port, e.g. PORT
+	External       ExternalMatch   `json:"external"`       // How to find an external process; Command, if set, restarts it
+	Lazy           bool            `json:"lazy"`           // Stay idle until traffic arrives through the proxy or Sockets
+	IdleTimeout    int             `json:"idleTimeout"`    // Seconds without connections before a lazy process is stopped, defaults to 600; negative never stops it
+	LogQueue       LogQueuePolicy  `json:"logQueue"`       // Output waiting for slow log sinks
+	Output         OutputPolicy    `json:"output"`         // How output is cut into lines
+	Multiline      MultilinePolicy `json:"multiline"`      // Lines grouped into one entry, such as stack traces
+	LevelPattern   string          `json:"levelPattern"`   // Regex finding the log level of a line, in a group named level
+	LogBuffer      int             `json:"logBuffer"`      // Log entries kept in memory for the log view, defaults to 100
 }
 
 type Snapshot struct {
-	Definition Definition `json:"definition"`
-	PID        int        `json:"pid"`
-	Status     Status     `json:"status"`
-	Restarts   int        `json:"restarts"`
-	LastError  string     `json:"lastError"`
-	StartedAt  *time.Time `json:"startedAt,omitempty"`
-	StoppedAt  *time.Time `json:"stoppedAt,omitempty"`
+	Definition   Definition     `json:"definition"`
+	PID          int            `json:"pid"`
+	Status       Status         `json:"status"`
+	Restarts     int            `json:"restarts"`
+	LastError    string         `json:"lastError"`
+	StartedAt    *time.Time     `json:"startedAt,omitempty"`
+	StoppedAt    *time.Time     `json:"stoppedAt,omitempty"`
+	ExitCode     *int           `json:"exitCode,omitempty"` // Exit code of the last run
+	Duration     int64          `json:"duration"`           // Duration of the last finished run in milliseconds
+	CrashLoop    CrashLoopState `json:"crashLoop"`
+	Instances    int            `json:"instances"`              // Live instances, two during a rolling restart
+	Listening    []string       `json:"listening,omitempty"`    // Bound addresses of Sockets
+	Ports        map[string]int `json:"ports,omitempty"`        // Allocated ports by env var
+	Connections  int            `json:"connections"`            // Open connections through the proxy
+	LastActivity *time.Time     `json:"lastActivity,omitempty"` // Last connection seen by a lazy process
+	DroppedLines int64          `json:"droppedLines"`           // Output lines dropped because the log queue was full
+	LogBacklog   int            `json:"logBacklog"`             // Output lines waiting for the log callback
 }
 
 // ProcessStats contains resource usage statistics
@@ -51,3 +169,13 @@ type ProcessStats struct {
 	MemoryMB   float64 `json:"memoryMB"`
 	Uptime     int64   `json:"uptime"` // seconds
 }
+
+// Orphan is a process group started by a previous ProcHub session that is
+// still alive.
+type Orphan struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"startedAt"`
+}
@@ -0,0 +1,173 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+var ErrUnresolvedReference = errors.New("unresolved process reference")
+
+// errPortUnallocated is returned by peekRefs for a port that is allocated
+// on first use
+var errPortUnallocated = errors.New("port will be allocated on start")
+
+// procRefPattern matches ${proc:<id or name>.<VAR>}
+var procRefPattern = regexp.MustCompile(`\$\{proc:([^.}]+)\.([^}]+)\}`)
+
+// resolve returns def as it is started: its env files loaded and ports
+// allocated into Env, and references to other processes expanded in Command,
+// Args, Env and the readiness target. Env takes precedence over ports, which
+// take precedence over env files. Callers must hold m.mu.
+func (m *Manager) resolve(def Definition) (Definition, error) {
+	env := make(Environment, len(def.Env))
+	for _, path := range def.EnvFiles {
+		values, err := loadEnvFile(envFilePath(def, path))
+		if err != nil {
+			return def, fmt.Errorf("env file: %w", err)
+		}
+		for key, value := range values {
+			if env[key], err = m.expandRefs(value); err != nil {
+				return def, err
+			}
+		}
+	}
+
+	ports, err := m.ports.ports(def.ID, def.Ports)
+	if err != nil {
+		return def, err
+	}
+	for name, port := range ports {
+		env[name] = strconv.Itoa(port)
+	}
+	for key, value := range def.Env {
+		if env[key], err = m.expandRefs(value); err != nil {
+			return def, err
+		}
+	}
+	def.Env = env
+
+	if def.Command, err = m.expandRefs(def.Command); err != nil {
+		return def, err
+	}
+	args := make([]string, len(def.Args))
+	for i, arg := range def.Args {
+		if args[i], err = m.expandRefs(arg); err != nil {
+			return def, err
+		}
+	}
+	def.Args = args
+
+	if def.Readiness.Target, err = m.expandRefs(def.Readiness.Target); err != nil {
+		return def, err
+	}
+	return def, nil
+}
+
+// allocatePorts allocates the ports of def and of the processes it
+// references, so that resolve finds them allocated. Finding free ports
+// probes the network, so m.mu is only held to look up the references; it
+// must not be held by the caller. References in env files are left to
+// resolve.
+func (m *Manager) allocatePorts(def Definition) error {
+	values := []string{def.Command, def.Readiness.Target}
+	values = append(values, def.Args...)
+	for _, value := range def.Env {
+		values = append(values, value)
+	}
+
+	wanted := map[string][]string{def.ID: def.Ports}
+	m.mu.RLock()
+	for _, value := range values {
+		for _, match := range procRefPattern.FindAllStringSubmatch(value, -1) {
+			if target := m.lookupEntry(match[1]); target != nil && slices.Contains(target.definition.Ports, match[2]) {
+				wanted[target.definition.ID] = target.definition.Ports
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	for id, names := range wanted {
+		if _, err := m.ports.ports(id, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandRefs replaces ${proc:<id or name>.<VAR>} with a port allocated to
+// that process or, failing that, a variable from its Env. Env values of the
+// referenced process are taken verbatim. Callers must hold m.mu.
+func (m *Manager) expandRefs(s string) (string, error) {
+	return m.replaceRefs(s, true)
+}
+
+// peekRefs is expandRefs without allocating ports. A reference to a port
+// not allocated yet expands to "" and fails with errPortUnallocated, unless
+// another reference fails. Callers must hold m.mu.
+func (m *Manager) peekRefs(s string) (string, error) {
+	return m.replaceRefs(s, false)
+}
+
+func (m *Manager) replaceRefs(s string, allocate bool) (string, error) {
+	var err error
+	expanded := procRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		match := procRefPattern.FindStringSubmatch(ref)
+		value, lookupErr := m.lookupRef(match[1], match[2], allocate)
+		switch {
+		case lookupErr == errPortUnallocated:
+			if err == nil {
+				err = fmt.Errorf("%s: %w", ref, lookupErr)
+			}
+		case lookupErr != nil && (err == nil || errors.Is(err, errPortUnallocated)):
+			err = fmt.Errorf("%w %s: %v", ErrUnresolvedReference, ref, lookupErr)
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// lookupRef resolves one reference, allocating the ports of the referenced
+// process if allocate is set. Callers must hold m.mu.
+func (m *Manager) lookupRef(process, name string, allocate bool) (string, error) {
+	target := m.lookupEntry(process)
+	if target == nil {
+		return "", ErrNotFound
+	}
+
+	def := target.definition
+	if slices.Contains(def.Ports, name) {
+		if !allocate {
+			if port, ok := m.ports.peek(def.ID)[name]; ok {
+				return strconv.Itoa(port), nil
+			}
+			return "", errPortUnallocated
+		}
+		// Allocate on demand so references do not depend on start order
+		ports, err := m.ports.ports(def.ID, def.Ports)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(ports[name]), nil
+	}
+	if value, ok := def.Env[name]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("%s has no port or variable %s", process, name)
+}
+
+// lookupEntry finds the entry of a process by ID or, failing that, by name.
+// Callers must hold m.mu.
+func (m *Manager) lookupEntry(process string) *entry {
+	if item, ok := m.entries[process]; ok {
+		return item
+	}
+	for _, item := range m.entries {
+		if item.definition.Name == process {
+			return item
+		}
+	}
+	return nil
+}
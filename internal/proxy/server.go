@@ -0,0 +1,326 @@
+package proxy
+
+import (
+	"context"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"prochub/internal/process"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// defaultStartWait bounds how long a request waits for a starting or lazy
+// process before getting the starting page
+const defaultStartWait = 30 * time.Second
+
+// Source gives the proxy the live state of the managed processes. Routes are
+// resolved against it on every request, so they follow starts, stops and
+// port changes without any bookkeeping.
+type Source interface {
+	List() []process.Snapshot
+	// Acquire waits for the process to be ready, starting it if it is lazy
+	// and idle, and counts the request as a connection until release
+	Acquire(ctx context.Context, id string) (release func(), err error)
+}
+
+// Server is the embedded HTTP and WebSocket reverse proxy.
+type Server struct {
+	source    Source
+	proxy     *httputil.ReverseProxy
+	startWait time.Duration
+
+	mu     sync.RWMutex
+	config Config
+	server *http.Server
+}
+
+type targetKey struct{}
+
+// target is where a request is forwarded, passed to the reverse proxy
+// through the request context
+type target struct {
+	url         *url.URL
+	stripPrefix string
+}
+
+func NewServer(source Source) *Server {
+	s := &Server{source: source, startWait: defaultStartWait}
+	s.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			t := pr.In.Context().Value(targetKey{}).(target)
+			if t.stripPrefix != "" {
+				pr.Out.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(pr.Out.URL.Path, t.stripPrefix), "/")
+				pr.Out.URL.RawPath = ""
+			}
+			pr.SetURL(t.url)
+			// Keep the original host for dev servers that check it
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writePage(w, http.StatusBadGateway, page{
+				Title:   "Process is not answering",
+				Message: err.Error(),
+				Refresh: true,
+			})
+		},
+	}
+	return s
+}
+
+// Apply reconfigures the proxy, restarting its listener when the address
+// changes. A disabled config stops it.
+func (s *Server) Apply(cfg Config) error {
+	if cfg.Address == "" {
+		cfg.Address = DefaultAddress
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	running := s.server != nil
+	if running && (!cfg.Enabled || cfg.Address != s.config.Address) {
+		s.shutdown()
+		running = false
+	}
+	s.config = cfg
+	if !cfg.Enabled || running {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return err
+	}
+	s.server = &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go s.server.Serve(l)
+	return nil
+}
+
+// Stop shuts the proxy down
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown()
+}
+
+// shutdown stops the listener. Callers must hold s.mu.
+func (s *Server) shutdown() {
+	if s.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+	}
+	s.server = nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	cfg := s.config
+	s.mu.RUnlock()
+
+	route, snap, ok := match(cfg, s.source.List(), r)
+	if !ok {
+		writePage(w, http.StatusNotFound, page{
+			Title:   "No route",
+			Message: "No process is routed for " + r.Host + r.URL.Path + ".",
+		})
+		return
+	}
+
+	name := snap.Definition.Name
+	if name == "" {
+		name = snap.Definition.ID
+	}
+	switch snap.Status {
+	case process.StatusRunning, process.StatusStarting, process.StatusBackoff, process.StatusIdle:
+	default:
+		writePage(w, http.StatusBadGateway, page{
+			Title:   name + " is " + string(snap.Status),
+			Message: "Start the process in ProcHub to reach it here.",
+			Refresh: true,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.startWait)
+	release, err := s.source.Acquire(ctx, snap.Definition.ID)
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			writePage(w, http.StatusBadGateway, page{
+				Title:   name + " did not start",
+				Message: err.Error(),
+				Refresh: true,
+			})
+			return
+		}
+		w.Header().Set("Retry-After", "2")
+		writePage(w, http.StatusServiceUnavailable, page{
+			Title:   name + " is starting",
+			Message: "This page reloads once the process is up.",
+			Refresh: true,
+		})
+		return
+	}
+	defer release()
+
+	// Ports of a lazy process are only known once it has started
+	for _, fresh := range s.source.List() {
+		if fresh.Definition.ID == snap.Definition.ID {
+			snap = fresh
+		}
+	}
+	port := targetPort(route, snap)
+	if port == 0 {
+		writePage(w, http.StatusBadGateway, page{
+			Title:   name + " has no port",
+			Message: "Give the process a port or set the port of the route.",
+		})
+		return
+	}
+
+	t := target{url: &url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(port))}}
+	if route.StripPrefix {
+		t.stripPrefix = strings.TrimSuffix(route.PathPrefix, "/")
+	}
+	s.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), targetKey{}, t)))
+}
+
+// match finds the route for r. Configured routes win over automatic hosts;
+// among them a route naming the host wins over a catch-all one, then the
+// longest path prefix wins.
+func match(cfg Config, snaps []process.Snapshot, r *http.Request) (Route, process.Snapshot, bool) {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	byID := make(map[string]process.Snapshot, len(snaps))
+	for _, snap := range snaps {
+		byID[snap.Definition.ID] = snap
+	}
+
+	var best Route
+	bestScore := -1
+	for _, route := range cfg.Routes {
+		if _, ok := byID[route.ProcessID]; !ok {
+			continue
+		}
+		if route.Host != "" && !strings.EqualFold(route.Host, host) {
+			continue
+		}
+		if !hasPathPrefix(r.URL.Path, route.PathPrefix) {
+			continue
+		}
+		score := len(route.PathPrefix)
+		if route.Host != "" {
+			score += 1 << 16
+		}
+		if score > bestScore {
+			best, bestScore = route, score
+		}
+	}
+	if bestScore >= 0 {
+		return best, byID[best.ProcessID], true
+	}
+
+	if cfg.AutoHosts {
+		for _, snap := range snaps {
+			if host == hostFor(snap.Definition.Name)+".localhost" || host == hostFor(snap.Definition.ID)+".localhost" {
+				return Route{ProcessID: snap.Definition.ID}, snap, true
+			}
+		}
+	}
+	return Route{}, process.Snapshot{}, false
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// hostFor turns a process name into a host label, e.g. "My API" -> "my-api"
+func hostFor(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// targetPort finds the port of the process a route points at: a fixed
+// number, an allocated port, a port set in its Env, or a socket ProcHub
+// holds for it.
+func targetPort(route Route, snap process.Snapshot) int {
+	name := route.Port
+	if name == "" {
+		name = "PORT"
+	}
+	if port, err := strconv.Atoi(name); err == nil {
+		return port
+	}
+	if port, ok := snap.Ports[name]; ok {
+		return port
+	}
+	if value, ok := snap.Definition.Env[name]; ok {
+		if port, err := strconv.Atoi(value); err == nil {
+			return port
+		}
+	}
+	for _, addr := range snap.Listening {
+		if _, p, err := net.SplitHostPort(addr); err == nil {
+			if port, err := strconv.Atoi(p); err == nil {
+				return port
+			}
+		}
+	}
+	return 0
+}
+
+type page struct {
+	Title   string
+	Message string
+	Refresh bool
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{if .Refresh}}<meta http-equiv="refresh" content="2">{{end}}
+<title>{{.Title}} - ProcHub</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; color: #333; display: flex; align-items: center; justify-content: center; height: 90vh; }
+main { text-align: center; }
+h1 { font-size: 1.5em; }
+p { color: #888; }
+</style>
+</head>
+<body><main><h1>{{.Title}}</h1><p>{{.Message}}</p></main></body>
+</html>
+`))
+
+func writePage(w http.ResponseWriter, status int, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = pageTemplate.Execute(w, p)
+}
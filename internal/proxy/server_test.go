@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"prochub/internal/process"
+)
+
+type fakeSource []process.Snapshot
+
+func (f fakeSource) List() []process.Snapshot { return f }
+
+func (f fakeSource) Acquire(ctx context.Context, id string) (func(), error) {
+	for _, snap := range f {
+		if snap.Definition.ID != id {
+			continue
+		}
+		if snap.Status == process.StatusRunning {
+			return func() {}, nil
+		}
+		// Never becomes ready
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, process.ErrNotFound
+}
+
+func TestServeHTTP(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host+" "+r.URL.Path)
+	}))
+	defer backend.Close()
+	_, portStr, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	source := fakeSource{
+		{Definition: process.Definition{ID: "proc-1", Name: "My API"}, Status: process.StatusRunning, Ports: map[string]int{"PORT": port}},
+		{Definition: process.Definition{ID: "proc-2", Name: "web"}, Status: process.StatusStopped},
+		{Definition: process.Definition{ID: "proc-3", Name: "worker"}, Status: process.StatusStarting},
+	}
+	s := NewServer(source)
+	s.startWait = 100 * time.Millisecond
+	s.config = Config{
+		AutoHosts: true,
+		Routes: []Route{
+			{PathPrefix: "/api", ProcessID: "proc-1", StripPrefix: true},
+			{Host: "web.test", ProcessID: "proc-2"},
+			{Host: "worker.test", ProcessID: "proc-3"},
+		},
+	}
+
+	tests := []struct {
+		host, path string
+		status     int
+		body       string
+	}{
+		{"anything.test", "/api/users", http.StatusOK, "anything.test /users"},
+		{"my-api.localhost:8080", "/health", http.StatusOK, "my-api.localhost:8080 /health"},
+		{"web.test", "/", http.StatusBadGateway, ""},
+		{"worker.test", "/", http.StatusServiceUnavailable, ""},
+		{"nothing.test", "/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+tt.path, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s%s: status = %d, want %d", tt.host, tt.path, rec.Code, tt.status)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s%s: body = %q, want %q", tt.host, tt.path, rec.Body.String(), tt.body)
+		}
+	}
+}
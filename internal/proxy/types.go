@@ -0,0 +1,21 @@
+package proxy
+
+// DefaultAddress is where the proxy listens unless configured otherwise
+const DefaultAddress = "127.0.0.1:8080"
+
+// Route sends requests matching Host and PathPrefix to a managed process.
+type Route struct {
+	Host        string `json:"host"`        // e.g. api.localhost; empty matches any host
+	PathPrefix  string `json:"pathPrefix"`  // e.g. /api; empty matches any path
+	ProcessID   string `json:"processId"`   // Target process
+	Port        string `json:"port"`        // Port env var of the process (default PORT) or a fixed port number
+	StripPrefix bool   `json:"stripPrefix"` // Remove PathPrefix before forwarding
+}
+
+// Config configures the embedded reverse proxy.
+type Config struct {
+	Enabled   bool    `json:"enabled"`
+	Address   string  `json:"address"`   // Listen address, defaults to DefaultAddress
+	AutoHosts bool    `json:"autoHosts"` // Route <name>.localhost to every process with a PORT
+	Routes    []Route `json:"routes"`
+}